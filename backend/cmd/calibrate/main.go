@@ -0,0 +1,156 @@
+// Command calibrate fits a confidence calibration table for one pipeline
+// stage from a labeled JSONL dataset of log entries:
+//
+//	{"source": "app", "log_message": "...", "label_id": "DB_ERROR"}
+//
+// Each entry is sent to the stage, and its raw confidence is paired with
+// whether the stage got the label right.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log-classifier/internal/calibration"
+	"log-classifier/internal/classifier"
+	"log-classifier/internal/config"
+	"log-classifier/internal/models"
+	"log-classifier/internal/taxonomy"
+	"os"
+)
+
+type labeledEntry struct {
+	models.LogEntry
+	LabelID string `json:"label_id"`
+}
+
+func main() {
+	stage := flag.String("stage", "bert", "stage to calibrate (regex, bayes, bert, llm or a plugin)")
+	data := flag.String("data", "", "labeled JSONL dataset")
+	method := flag.String("method", "platt", "calibration method (platt, isotonic)")
+	out := flag.String("out", "", "output file")
+	configPath := flag.String("config", os.Getenv("LOG_CLASSIFIER_CONFIG"), "server config file")
+	flag.Parse()
+
+	if *data == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	labels, err := taxonomy.FromConfig(cfg.Taxonomy)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	taxonomy.Set(labels)
+	classifier.ConfigureBERTCanary(cfg.BERT.URL, "", 0)
+	if err := classifier.ConfigureBayes(cfg.Bayes); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if err := classifier.ConfigurePlugins(cfg.Plugins); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	defer classifier.ClosePlugins()
+	if err := classifier.ConfigureLLM(cfg.LLM); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	// the stage must see the same input as in the server
+	if err := classifier.ConfigureNormalization(cfg.Normalize); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if err := classifier.ConfigureRedaction(cfg.Redact); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	entries, err := readDataset(*data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var samples []calibration.Sample
+	var correct int
+	for _, e := range entries {
+		result, err := classifier.ClassifyRaw(*stage, e.LogEntry)
+		if err != nil {
+			log.Printf("skipping %q: %v", e.LogMessage, err)
+			continue
+		}
+		if result == nil {
+			continue
+		}
+		ok := result.LabelID == e.LabelID
+		if ok {
+			correct++
+		}
+		samples = append(samples, calibration.Sample{Score: result.Confidence, Correct: ok})
+	}
+	if len(samples) == 0 {
+		log.Fatalf("stage %s produced no results for %s", *stage, *data)
+	}
+
+	var c calibration.Calibrator
+	switch *method {
+	case "platt":
+		c, err = calibration.FitPlatt(samples)
+	case "isotonic":
+		c, err = calibration.FitIsotonic(samples)
+	default:
+		err = fmt.Errorf("unknown method %q", *method)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := calibration.Save(*out, c); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s: %d samples, accuracy %.3f\n", *stage, len(samples), float64(correct)/float64(len(samples)))
+	fmt.Printf("brier score: raw %.4f, calibrated %.4f\n", brier(samples, nil), brier(samples, c))
+	fmt.Printf("wrote %s calibration to %s\n", *method, *out)
+}
+
+func readDataset(path string) ([]labeledEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer f.Close()
+
+	var entries []labeledEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e labeledEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// brier is the mean squared error of the (calibrated) confidences.
+func brier(samples []calibration.Sample, c calibration.Calibrator) float64 {
+	var sum float64
+	for _, s := range samples {
+		p := s.Score
+		if c != nil {
+			p = c.Calibrate(p)
+		}
+		y := 0.0
+		if s.Correct {
+			y = 1
+		}
+		sum += (p - y) * (p - y)
+	}
+	return sum / float64(len(samples))
+}
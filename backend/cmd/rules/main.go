@@ -0,0 +1,100 @@
+// Command rules checks regex rule files.
+//
+//	rules test [-config server.yaml] rules.yaml...
+//	rules lint [-config server.yaml] rules.yaml...
+//
+// test: every rule must compile, use a label from the taxonomy and match
+// all of its positive examples and none of its negative ones.
+//
+// lint: additionally reports rules shadowed by earlier rules and rules
+// that match another rule's examples with a different label.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log-classifier/internal/config"
+	"log-classifier/internal/rules"
+	"log-classifier/internal/taxonomy"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rules test|lint [-config server.yaml] rules.yaml...")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "test" && os.Args[1] != "lint") {
+		usage()
+	}
+	lint := os.Args[1] == "lint"
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	configPath := fs.String("config", os.Getenv("LOG_CLASSIFIER_CONFIG"), "server config file (for the label taxonomy)")
+	fs.Parse(os.Args[2:])
+	if fs.NArg() == 0 {
+		usage()
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(1)
+	}
+	reg, err := taxonomy.FromConfig(cfg.Taxonomy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, path := range fs.Args() {
+		if !testFile(path, reg, lint) {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func testFile(path string, reg *taxonomy.Registry, lint bool) bool {
+	rs, err := rules.Load(path)
+	if err != nil {
+		fmt.Printf("FAIL %s\n  %v\n", path, err)
+		return false
+	}
+
+	c, err := rules.Compile(rs, reg)
+	var exErr *rules.ExampleError
+	switch {
+	case errors.As(err, &exErr):
+		fmt.Printf("FAIL %s (version %s)\n", path, rs.Version)
+		for _, f := range exErr.Failures {
+			fmt.Printf("  %s\n", f)
+		}
+		return false
+	case err != nil:
+		fmt.Printf("FAIL %s\n  %v\n", path, err)
+		return false
+	}
+
+	if lint {
+		if findings := c.Lint(); len(findings) > 0 {
+			fmt.Printf("FAIL %s (version %s)\n", path, rs.Version)
+			for _, f := range findings {
+				fmt.Printf("  %s\n", f)
+			}
+			return false
+		}
+	}
+
+	examples := 0
+	for _, r := range c.Rules() {
+		examples += len(r.Examples.Positive) + len(r.Examples.Negative)
+	}
+	fmt.Printf("ok   %s (version %s): %d rules, %d examples\n", path, rs.Version, len(c.Rules()), examples)
+	return true
+}
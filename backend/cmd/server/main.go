@@ -3,7 +3,14 @@ package main
 import (
 	"log"
 	"log-classifier/internal/api"
+	"log-classifier/internal/classifier"
+	"log-classifier/internal/config"
+	"log-classifier/internal/rules"
+	"log-classifier/internal/taxonomy"
+	"maps"
 	"net/http"
+	"os"
+	"slices"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -33,8 +40,8 @@ func init() {
 func enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, PUT, DELETE, OPTIONS")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -54,6 +61,67 @@ func loggingMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
+	cfg, err := config.Load(os.Getenv("LOG_CLASSIFIER_CONFIG"))
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	labels, err := taxonomy.FromConfig(cfg.Taxonomy)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	taxonomy.Set(labels)
+
+	ruleSet := rules.Default()
+	if cfg.Regex.RulesFile != "" {
+		if ruleSet, err = rules.Load(cfg.Regex.RulesFile); err != nil {
+			log.Fatalf("regex rules: %v", err)
+		}
+	}
+	ruleStore, err := rules.OpenStore(ruleSet, taxonomy.Current, slices.Collect(maps.Keys(cfg.Routes)), cfg.Admin.AuditLog, classifier.SetRegexRules)
+	if err != nil {
+		log.Fatalf("regex rules: %v", err)
+	}
+
+	classifier.ConfigurePipeline(cfg.Pipeline)
+	if err := classifier.ConfigureLLM(cfg.LLM); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	classifier.ConfigureTemplates(cfg.Templates)
+	if err := classifier.ConfigureBayes(cfg.Bayes); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if err := classifier.ConfigurePlugins(cfg.Plugins); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if err := classifier.ConfigureNormalization(cfg.Normalize); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if err := classifier.ConfigureRedaction(cfg.Redact); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if err := classifier.ConfigureSecretDetection(cfg.Secrets); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if err := classifier.ConfigureRoutes(cfg.Routes); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if err := classifier.ConfigureThresholds(cfg.Thresholds, cfg.Calibration); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	classifier.ConfigureBERTCanary(cfg.BERT.URL, cfg.BERT.CanaryURL, cfg.BERT.CanaryPercent)
+	if cfg.BERT.CanaryPercent > 0 {
+		log.Printf("BERT canary enabled: %d%% of traffic to %s", cfg.BERT.CanaryPercent, cfg.BERT.CanaryURL)
+	}
+
+	if err := classifier.ConfigureShadow(cfg.Shadow); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	if err := classifier.ConfigureEnsemble(cfg.Ensemble); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/classify", func(w http.ResponseWriter, r *http.Request) {
@@ -65,13 +133,20 @@ func main() {
 		classifyTotal.WithLabelValues("success").Inc()
 	})
 
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"healthy"}`))
-	})
+	mux.HandleFunc("/health", api.HealthHandler)
+
+	mux.HandleFunc("/labels", api.LabelsHandler)
+	mux.HandleFunc("/shadow/disagreements", api.ShadowDisagreementsHandler)
+	mux.HandleFunc("/rules/dead", api.DeadRulesHandler)
+	mux.HandleFunc("/templates", api.TemplatesHandler)
 
 	mux.Handle("/metrics", promhttp.Handler())
 
+	if len(cfg.Admin.Tokens) > 0 {
+		admin := &api.RulesAdmin{Store: ruleStore, Tokens: cfg.Admin.Tokens}
+		admin.Register(mux)
+	}
+
 	handler := loggingMiddleware(enableCORS(mux))
 
 	log.Println("Server running on :8080")
@@ -0,0 +1,110 @@
+// Command train fits the model for the bayes stage from a labeled
+// dataset, either JSONL as for the calibrate command or CSV with
+// log_message and label_id columns:
+//
+//	train -data labeled.jsonl -out bayes.json
+//
+// Messages are redacted and normalized as the server would for the
+// bayes stage. A fraction of each label's examples is held out to report
+// accuracy, then the saved model is trained on the whole dataset.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"log-classifier/internal/bayes"
+	"log-classifier/internal/classifier"
+	"log-classifier/internal/config"
+	"log-classifier/internal/taxonomy"
+	"os"
+	"slices"
+)
+
+func main() {
+	data := flag.String("data", "", "labeled dataset (.jsonl or .csv)")
+	out := flag.String("out", "", "output model file")
+	holdout := flag.Float64("holdout", 0.2, "fraction of each label held out for evaluation")
+	seed := flag.Uint64("seed", 1, "seed for the held-out split")
+	buckets := flag.Int("buckets", 1<<20, "hashed feature space")
+	alpha := flag.Float64("alpha", 0.1, "additive smoothing")
+	configPath := flag.String("config", os.Getenv("LOG_CLASSIFIER_CONFIG"), "server config file")
+	flag.Parse()
+
+	if *data == "" || *out == "" || *holdout < 0 || *holdout >= 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	labels, err := taxonomy.FromConfig(cfg.Taxonomy)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	taxonomy.Set(labels)
+	// the model must learn from the same input as in the server
+	if err := classifier.ConfigureNormalization(cfg.Normalize); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if err := classifier.ConfigureRedaction(cfg.Redact); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	examples, err := bayes.ReadExamples(*data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, ex := range examples {
+		if _, ok := taxonomy.Current().Lookup(ex.LabelID); !ok {
+			log.Fatalf("%s: example %d: label %q is not in the taxonomy", *data, i+1, ex.LabelID)
+		}
+		examples[i].Message = classifier.StageInput("bayes", ex.Message)
+	}
+
+	tc := bayes.Config{Buckets: *buckets, Alpha: *alpha}
+	if *holdout > 0 {
+		train, test := bayes.Split(examples, *holdout, *seed)
+		m, err := bayes.Train(train, tc)
+		if err != nil {
+			log.Fatal(err)
+		}
+		report(m, len(train), test)
+	}
+
+	m, err := bayes.Train(examples, tc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := bayes.Save(*out, m); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("wrote model with %d labels, trained on %d examples, to %s\n", len(m.Labels), len(examples), *out)
+}
+
+// report prints the held-out accuracy overall and per label.
+func report(m *bayes.Model, trained int, test []bayes.Example) {
+	if len(test) == 0 {
+		fmt.Println("no examples held out")
+		return
+	}
+	total, correct := map[string]int{}, map[string]int{}
+	for _, ex := range test {
+		total[ex.LabelID]++
+		if m.Predict(ex.Message)[0].LabelID == ex.LabelID {
+			correct[ex.LabelID]++
+		}
+	}
+
+	fmt.Printf("trained on %d examples, held out %d: accuracy %.3f\n", trained, len(test), m.Accuracy(test))
+	ids := make([]string, 0, len(total))
+	for id := range total {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		fmt.Printf("  %-24s %4d/%-4d %.3f\n", id, correct[id], total[id], float64(correct[id])/float64(total[id]))
+	}
+}
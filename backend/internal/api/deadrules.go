@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"log-classifier/internal/classifier"
+)
+
+const defaultDeadRuleWindow = 24 * time.Hour
+
+// DeadRulesHandler lists the enabled regex rules with no hits over
+// ?window= (a Go duration, default 24h).
+func DeadRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	window := defaultDeadRuleWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid window", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+
+	writeJSON(w, http.StatusOK, classifier.DeadRules(window))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"log-classifier/internal/classifier"
 	"log-classifier/internal/models"
 	"log-classifier/internal/worker"
 )
@@ -25,7 +26,12 @@ func ClassifyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	results := worker.ProcessLogs(logs, 4)
+	opts := classifier.Options{
+		Explain:    r.URL.Query().Get("explain") == "true",
+		MultiLabel: r.URL.Query().Get("multi_label") == "true",
+	}
+
+	results := worker.ProcessLogsWithOptions(logs, 4, opts)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results)
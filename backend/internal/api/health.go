@@ -0,0 +1,28 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"log-classifier/internal/classifier"
+)
+
+type healthResponse struct {
+	Status   string   `json:"status"`
+	Degraded bool     `json:"degraded"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+// HealthHandler reports whether the server is healthy or running degraded
+// because a downstream classifier is unavailable.
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{Status: "healthy"}
+	if reasons := classifier.DegradedReasons(); len(reasons) > 0 {
+		resp.Status = "degraded"
+		resp.Degraded = true
+		resp.Reasons = reasons
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
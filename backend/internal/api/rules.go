@@ -0,0 +1,150 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"log-classifier/internal/rules"
+)
+
+// RulesAdmin serves the authenticated regex rule management endpoints.
+type RulesAdmin struct {
+	Store  *rules.Store
+	Tokens map[string]string // user name -> bearer token
+}
+
+// Register adds the admin routes to mux.
+func (a *RulesAdmin) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/rules", a.auth(a.list))
+	mux.HandleFunc("POST /admin/rules", a.auth(a.create))
+	mux.HandleFunc("PUT /admin/rules/{id}", a.auth(a.update))
+	mux.HandleFunc("DELETE /admin/rules/{id}", a.auth(a.delete))
+	mux.HandleFunc("POST /admin/rules/{id}/disable", a.auth(a.setEnabled(false)))
+	mux.HandleFunc("POST /admin/rules/{id}/enable", a.auth(a.setEnabled(true)))
+	mux.HandleFunc("GET /admin/rules/audit", a.auth(a.audit))
+	mux.HandleFunc("GET /admin/rules/lint", a.auth(a.lint))
+	mux.HandleFunc("POST /admin/rules/revisions/{revision}/rollback", a.auth(a.rollback))
+}
+
+type actorHandler func(w http.ResponseWriter, r *http.Request, actor string)
+
+// auth resolves the bearer token to a user name.
+func (a *RulesAdmin) auth(next actorHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && strings.TrimSpace(token) != "" {
+			for user, t := range a.Tokens {
+				if strings.TrimSpace(t) != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+					next(w, r, user)
+					return
+				}
+			}
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}
+}
+
+type rulesResponse struct {
+	Version  string       `json:"version"`
+	Revision int          `json:"revision"`
+	Rules    []rules.Rule `json:"rules"`
+}
+
+func (a *RulesAdmin) list(w http.ResponseWriter, r *http.Request, _ string) {
+	rs, rev := a.Store.Snapshot()
+	writeJSON(w, http.StatusOK, rulesResponse{Version: rs.Version, Revision: rev, Rules: rs.Rules})
+}
+
+func (a *RulesAdmin) create(w http.ResponseWriter, r *http.Request, actor string) {
+	var rule rules.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := a.Store.Create(actor, rule)
+	a.respond(w, http.StatusCreated, entry, err)
+}
+
+func (a *RulesAdmin) update(w http.ResponseWriter, r *http.Request, actor string) {
+	var rule rules.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := a.Store.Update(actor, r.PathValue("id"), rule)
+	a.respond(w, http.StatusOK, entry, err)
+}
+
+func (a *RulesAdmin) delete(w http.ResponseWriter, r *http.Request, actor string) {
+	entry, err := a.Store.Delete(actor, r.PathValue("id"))
+	a.respond(w, http.StatusOK, entry, err)
+}
+
+func (a *RulesAdmin) setEnabled(enabled bool) actorHandler {
+	return func(w http.ResponseWriter, r *http.Request, actor string) {
+		entry, err := a.Store.SetEnabled(actor, r.PathValue("id"), enabled)
+		a.respond(w, http.StatusOK, entry, err)
+	}
+}
+
+func (a *RulesAdmin) rollback(w http.ResponseWriter, r *http.Request, actor string) {
+	rev, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+	entry, err := a.Store.Rollback(actor, rev)
+	a.respond(w, http.StatusOK, entry, err)
+}
+
+// audit lists the change history without the full rule snapshots.
+func (a *RulesAdmin) audit(w http.ResponseWriter, r *http.Request, _ string) {
+	history := a.Store.History()
+	for i := range history {
+		history[i].Rules = nil
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// lint reports shadowed and overlapping rules in the current rule set.
+func (a *RulesAdmin) lint(w http.ResponseWriter, r *http.Request, _ string) {
+	findings, err := a.Store.Lint()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if findings == nil {
+		findings = []rules.LintFinding{}
+	}
+	writeJSON(w, http.StatusOK, findings)
+}
+
+func (a *RulesAdmin) respond(w http.ResponseWriter, status int, entry rules.AuditEntry, err error) {
+	var exErr *rules.ExampleError
+	switch {
+	case err == nil:
+		entry.Rules = nil
+		writeJSON(w, status, entry)
+	case errors.Is(err, rules.ErrRuleNotFound), errors.Is(err, rules.ErrNoSuchRev):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, rules.ErrRuleExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, rules.ErrEmptyPattern):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.As(err, &exErr):
+		writeJSON(w, http.StatusUnprocessableEntity, exErr.Failures)
+	default:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"log-classifier/internal/rules"
+	"log-classifier/internal/taxonomy"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRulesAdmin_RejectsEmptyTokens(t *testing.T) {
+	a := &RulesAdmin{Tokens: map[string]string{"alice": "s3cret", "broken": ""}}
+	h := a.auth(func(w http.ResponseWriter, r *http.Request, actor string) {
+		w.Write([]byte(actor))
+	})
+
+	for header, want := range map[string]int{
+		"Bearer s3cret": http.StatusOK,
+		"Bearer ":       http.StatusUnauthorized,
+		"Bearer   ":     http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"":              http.StatusUnauthorized,
+	} {
+		r := httptest.NewRequest("GET", "/admin/rules", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != want {
+			t.Errorf("%q: got %d, want %d", header, w.Code, want)
+		}
+	}
+}
+
+func TestRulesAdmin_RejectsUnknownRoutes(t *testing.T) {
+	store, err := rules.OpenStore(rules.Default(), taxonomy.Current, []string{"security"}, "", func(*rules.Compiled) {})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	mux := http.NewServeMux()
+	(&RulesAdmin{Store: store, Tokens: map[string]string{"alice": "s3cret"}}).Register(mux)
+
+	for _, tc := range []struct {
+		method, path, route string
+		want                int
+	}{
+		{"POST", "/admin/rules", "secuirty", http.StatusUnprocessableEntity},
+		{"POST", "/admin/rules", "security", http.StatusCreated},
+		{"PUT", "/admin/rules/intrusion", "nowhere", http.StatusUnprocessableEntity},
+	} {
+		body := `{"id": "intrusion", "pattern": "(?i)intrusion", "action": "route", "route": "` + tc.route + `"}`
+		r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s %s with route %q: got %d, want %d: %s", tc.method, tc.path, tc.route, w.Code, tc.want, w.Body)
+		}
+	}
+}
+
+func TestRulesAdmin_RejectsEmptyPatterns(t *testing.T) {
+	store, err := rules.OpenStore(rules.Default(), taxonomy.Current, nil, "", func(*rules.Compiled) {})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	mux := http.NewServeMux()
+	(&RulesAdmin{Store: store, Tokens: map[string]string{"alice": "s3cret"}}).Register(mux)
+
+	for _, pattern := range []string{"", "   "} {
+		body := `{"id": "everything", "pattern": "` + pattern + `", "label": "INFO"}`
+		r := httptest.NewRequest("POST", "/admin/rules", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("pattern %q: got %d, want %d: %s", pattern, w.Code, http.StatusBadRequest, w.Body)
+		}
+	}
+	if _, rev := store.Snapshot(); rev != 0 {
+		t.Fatalf("a rejected rule must not be stored, got revision %d", rev)
+	}
+}
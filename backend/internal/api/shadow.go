@@ -0,0 +1,25 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"log-classifier/internal/classifier"
+)
+
+// ShadowDisagreementsHandler serves the shadow disagreement log as a
+// downloadable JSON Lines file.
+func ShadowDisagreementsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="shadow-disagreements.jsonl"`)
+
+	enc := json.NewEncoder(w)
+	for _, d := range classifier.ShadowDisagreements() {
+		enc.Encode(d)
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"log-classifier/internal/classifier"
+)
+
+const defaultTemplateLimit = 100
+
+type templateResponse struct {
+	ID         int     `json:"id"`
+	Template   string  `json:"template"`
+	Count      uint64  `json:"count"`
+	LabelID    string  `json:"label_id,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Classifier string  `json:"classifier,omitempty"`
+}
+
+// TemplatesHandler lists the learned log templates, most frequent first,
+// with the label each was given. ?limit= caps the list (default 100).
+func TemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultTemplateLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	all := classifier.Templates()
+	resp := make([]templateResponse, 0, min(limit, len(all)))
+	for _, t := range all[:min(limit, len(all))] {
+		resp = append(resp, templateResponse{
+			ID:         t.ID,
+			Template:   t.String(),
+			Count:      t.Count,
+			LabelID:    t.LabelID,
+			Confidence: t.Confidence,
+			Classifier: t.Classifier,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
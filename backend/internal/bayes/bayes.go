@@ -0,0 +1,251 @@
+// Package bayes is a multinomial naive Bayes text classifier over hashed
+// TF-IDF features, trained and run in pure Go.
+//
+// Messages are lowercased and split into words; words with digits become
+// a single number token. Words and word pairs are hashed into Buckets
+// features. Each message is weighted by sublinear TF-IDF and scaled to
+// unit length before training and prediction, following Rennie et al.,
+// "Tackling the Poor Assumptions of Naive Bayes Text Classifiers" (2003).
+package bayes
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Example is a labeled message.
+type Example struct {
+	Message string
+	LabelID string
+}
+
+// Config tunes training. Zero fields take the defaults.
+type Config struct {
+	Buckets int     // hashed feature space, default 1<<20
+	Alpha   float64 // additive smoothing, default 0.1
+}
+
+func (c Config) withDefaults() Config {
+	if c.Buckets <= 0 {
+		c.Buckets = 1 << 20
+	}
+	if c.Alpha <= 0 {
+		c.Alpha = 0.1
+	}
+	return c
+}
+
+// Model is a trained classifier. Likelihoods are stored only for the
+// features seen with a label; every other feature has the label's
+// Unseen log-likelihood.
+type Model struct {
+	Buckets       int                  `json:"buckets"`
+	Labels        []string             `json:"labels"`
+	LogPrior      []float64            `json:"log_prior"`
+	LogLikelihood []map[uint32]float64 `json:"log_likelihood"`
+	Unseen        []float64            `json:"unseen"`
+	IDF           map[uint32]float64   `json:"idf"`
+	UnseenIDF     float64              `json:"unseen_idf"`
+}
+
+// Prediction is a label and its posterior probability.
+type Prediction struct {
+	LabelID     string
+	Probability float64
+}
+
+// Train fits a model to examples.
+func Train(examples []Example, cfg Config) (*Model, error) {
+	cfg = cfg.withDefaults()
+	if len(examples) == 0 {
+		return nil, fmt.Errorf("bayes: no training examples")
+	}
+
+	index := make(map[string]int)
+	var labels []string
+	for _, ex := range examples {
+		if _, ok := index[ex.LabelID]; !ok {
+			index[ex.LabelID] = len(labels)
+			labels = append(labels, ex.LabelID)
+		}
+	}
+	if len(labels) < 2 {
+		return nil, fmt.Errorf("bayes: need at least two labels, got %v", labels)
+	}
+
+	counts := make([]map[uint32]float64, len(examples))
+	df := make(map[uint32]int)
+	for i, ex := range examples {
+		counts[i] = termCounts(ex.Message, cfg.Buckets)
+		for f := range counts[i] {
+			df[f]++
+		}
+	}
+
+	n := float64(len(examples))
+	m := &Model{
+		Buckets:       cfg.Buckets,
+		Labels:        labels,
+		LogPrior:      make([]float64, len(labels)),
+		LogLikelihood: make([]map[uint32]float64, len(labels)),
+		Unseen:        make([]float64, len(labels)),
+		IDF:           make(map[uint32]float64, len(df)),
+		UnseenIDF:     math.Log(n+1) + 1,
+	}
+	for f, d := range df {
+		m.IDF[f] = math.Log((n+1)/float64(d+1)) + 1
+	}
+
+	weights := make([]map[uint32]float64, len(labels))
+	totals := make([]float64, len(labels))
+	for l := range labels {
+		weights[l] = make(map[uint32]float64)
+	}
+	for i, ex := range examples {
+		l := index[ex.LabelID]
+		m.LogPrior[l]++
+		for f, w := range m.vector(counts[i]) {
+			weights[l][f] += w
+			totals[l] += w
+		}
+	}
+
+	for l := range labels {
+		m.LogPrior[l] = math.Log(m.LogPrior[l] / n)
+		denom := totals[l] + cfg.Alpha*float64(cfg.Buckets)
+		m.Unseen[l] = math.Log(cfg.Alpha / denom)
+		m.LogLikelihood[l] = make(map[uint32]float64, len(weights[l]))
+		for f, w := range weights[l] {
+			m.LogLikelihood[l][f] = math.Log((w + cfg.Alpha) / denom)
+		}
+	}
+	return m, nil
+}
+
+// Predict returns every label with its probability, most likely first.
+func (m *Model) Predict(msg string) []Prediction {
+	x := m.vector(termCounts(msg, m.Buckets))
+
+	scores := make([]float64, len(m.Labels))
+	for l := range m.Labels {
+		s := m.LogPrior[l]
+		for f, w := range x {
+			ll, ok := m.LogLikelihood[l][f]
+			if !ok {
+				ll = m.Unseen[l]
+			}
+			s += w * ll
+		}
+		scores[l] = s
+	}
+
+	// softmax, shifted by the best score to avoid underflow
+	best := slices.Max(scores)
+	var sum float64
+	for l, s := range scores {
+		scores[l] = math.Exp(s - best)
+		sum += scores[l]
+	}
+	out := make([]Prediction, len(m.Labels))
+	for l, id := range m.Labels {
+		out[l] = Prediction{LabelID: id, Probability: scores[l] / sum}
+	}
+	slices.SortStableFunc(out, func(a, b Prediction) int {
+		switch {
+		case a.Probability > b.Probability:
+			return -1
+		case a.Probability < b.Probability:
+			return 1
+		}
+		return 0
+	})
+	return out
+}
+
+// vector turns raw term counts into a unit-length TF-IDF vector.
+func (m *Model) vector(counts map[uint32]float64) map[uint32]float64 {
+	x := make(map[uint32]float64, len(counts))
+	var norm float64
+	for f, c := range counts {
+		idf, ok := m.IDF[f]
+		if !ok {
+			idf = m.UnseenIDF
+		}
+		w := (1 + math.Log(c)) * idf
+		x[f] = w
+		norm += w * w
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for f := range x {
+			x[f] /= norm
+		}
+	}
+	return x
+}
+
+// termCounts counts the hashed words and word pairs of msg.
+func termCounts(msg string, buckets int) map[uint32]float64 {
+	words := tokens(msg)
+	counts := make(map[uint32]float64, 2*len(words))
+	for i, w := range words {
+		counts[hash(w, buckets)]++
+		if i > 0 {
+			counts[hash(words[i-1]+" "+w, buckets)]++
+		}
+	}
+	return counts
+}
+
+// numberToken replaces words that contain digits.
+const numberToken = "<num>"
+
+func tokens(msg string) []string {
+	words := strings.FieldsFunc(strings.ToLower(msg), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for i, w := range words {
+		if strings.IndexFunc(w, unicode.IsDigit) >= 0 {
+			words[i] = numberToken
+		}
+	}
+	return words
+}
+
+func hash(s string, buckets int) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32() % uint32(buckets)
+}
+
+// Save writes the model to path as JSON.
+func Save(path string, m *Model) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Load reads a model written by Save.
+func Load(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model: %w", err)
+	}
+	var m Model
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Buckets <= 0 || len(m.Labels) == 0 || len(m.LogPrior) != len(m.Labels) ||
+		len(m.LogLikelihood) != len(m.Labels) || len(m.Unseen) != len(m.Labels) {
+		return nil, fmt.Errorf("%s: not a bayes model", path)
+	}
+	return &m, nil
+}
@@ -0,0 +1,119 @@
+package bayes
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func trainingSet() []Example {
+	var examples []Example
+	for i := range 20 {
+		examples = append(examples,
+			Example{Message: fmt.Sprintf("Connection to database db-%d refused after %dms", i, 100+i), LabelID: "DB_ERROR"},
+			Example{Message: fmt.Sprintf("Deadlock detected on table orders, query %d rolled back", i), LabelID: "DB_ERROR"},
+			Example{Message: fmt.Sprintf("User user%d logged in from 10.0.0.%d", i, i), LabelID: "USER_ACTION"},
+			Example{Message: fmt.Sprintf("User user%d updated profile settings", i), LabelID: "USER_ACTION"},
+			Example{Message: fmt.Sprintf("Escalation rule execution failed for ticket %d", 4000+i), LabelID: "WORKFLOW_ERROR"},
+			Example{Message: fmt.Sprintf("Workflow step approve-%d timed out waiting for manager", i), LabelID: "WORKFLOW_ERROR"},
+		)
+	}
+	return examples
+}
+
+func TestModel_PredictsTrainedLabels(t *testing.T) {
+	m, err := Train(trainingSet(), Config{Buckets: 1 << 16})
+	if err != nil {
+		t.Fatalf("train: %v", err)
+	}
+
+	tests := map[string]string{
+		"Connection to database db-99 refused after 3000ms": "DB_ERROR",
+		"User alice logged in from 192.168.1.1":             "USER_ACTION",
+		"Escalation rule execution failed for ticket 99999": "WORKFLOW_ERROR",
+	}
+	for msg, want := range tests {
+		p := m.Predict(msg)
+		if p[0].LabelID != want {
+			t.Errorf("%q: got %+v, want %s", msg, p, want)
+		}
+		var sum float64
+		for i, pr := range p {
+			sum += pr.Probability
+			if i > 0 && pr.Probability > p[i-1].Probability {
+				t.Errorf("%q: predictions not sorted: %+v", msg, p)
+			}
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("%q: probabilities sum to %v", msg, sum)
+		}
+	}
+}
+
+func TestTrain_NeedsTwoLabels(t *testing.T) {
+	if _, err := Train([]Example{{Message: "a", LabelID: "INFO"}}, Config{}); err == nil {
+		t.Fatalf("expected an error for a single label")
+	}
+}
+
+func TestSaveLoad_RoundTrip(t *testing.T) {
+	m, err := Train(trainingSet(), Config{Buckets: 1 << 16})
+	if err != nil {
+		t.Fatalf("train: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := Save(path, m); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	msg := "Deadlock detected on table invoices"
+	want, got := m.Predict(msg), loaded.Predict(msg)
+	for i := range want {
+		if want[i].LabelID != got[i].LabelID || math.Abs(want[i].Probability-got[i].Probability) > 1e-12 {
+			t.Fatalf("loaded model predicts %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestSplit_HoldsOutEveryLabel(t *testing.T) {
+	train, test := Split(trainingSet(), 0.25, 1)
+	if len(train)+len(test) != 120 || len(test) != 30 {
+		t.Fatalf("got %d train and %d test examples", len(train), len(test))
+	}
+	seen := map[string]int{}
+	for _, ex := range test {
+		seen[ex.LabelID]++
+	}
+	if len(seen) != 3 || seen["DB_ERROR"] != 10 {
+		t.Fatalf("expected a stratified split, got %v", seen)
+	}
+
+	m, err := Train(train, Config{})
+	if err != nil {
+		t.Fatalf("train: %v", err)
+	}
+	if acc := m.Accuracy(test); acc < 0.95 {
+		t.Fatalf("held-out accuracy %.2f", acc)
+	}
+}
+
+func TestReadExamples_CSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	data := "source,label_id,log_message\napp,DB_ERROR,\"Connection refused, retrying\"\napp,INFO,Backup started\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	examples, err := ReadExamples(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(examples) != 2 || examples[0] != (Example{Message: "Connection refused, retrying", LabelID: "DB_ERROR"}) {
+		t.Fatalf("unexpected examples: %+v", examples)
+	}
+}
@@ -0,0 +1,115 @@
+package bayes
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ReadExamples reads a labeled dataset. A .csv file needs a header with
+// log_message and label_id columns; anything else is read as JSONL with
+// one {"log_message": ..., "label_id": ...} object per line, the format
+// the calibrate command uses.
+func ReadExamples(path string) ([]Example, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readCSV(path, f)
+	}
+	return readJSONL(path, f)
+}
+
+func readCSV(path string, r io.Reader) ([]Example, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	msgCol, labelCol := slices.Index(header, "log_message"), slices.Index(header, "label_id")
+	if msgCol < 0 || labelCol < 0 {
+		return nil, fmt.Errorf("%s: header needs log_message and label_id columns", path)
+	}
+
+	var examples []Example
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return examples, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		examples = append(examples, Example{Message: rec[msgCol], LabelID: rec[labelCol]})
+	}
+}
+
+func readJSONL(path string, r io.Reader) ([]Example, error) {
+	var examples []Example
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e struct {
+			LogMessage string `json:"log_message"`
+			LabelID    string `json:"label_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		examples = append(examples, Example{Message: e.LogMessage, LabelID: e.LabelID})
+	}
+	return examples, scanner.Err()
+}
+
+// Split holds out a fraction of each label's examples for evaluation,
+// shuffled with seed. Labels with a single example are only trained on.
+func Split(examples []Example, holdout float64, seed uint64) (train, test []Example) {
+	byLabel := make(map[string][]Example)
+	var labels []string
+	for _, ex := range examples {
+		if _, ok := byLabel[ex.LabelID]; !ok {
+			labels = append(labels, ex.LabelID)
+		}
+		byLabel[ex.LabelID] = append(byLabel[ex.LabelID], ex)
+	}
+
+	rng := rand.New(rand.NewPCG(seed, seed))
+	for _, l := range labels {
+		group := byLabel[l]
+		rng.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		n := int(float64(len(group)) * holdout)
+		if n == 0 && holdout > 0 && len(group) > 1 {
+			n = 1
+		}
+		test = append(test, group[:n]...)
+		train = append(train, group[n:]...)
+	}
+	return train, test
+}
+
+// Accuracy is the fraction of examples whose most likely label is right.
+func (m *Model) Accuracy(examples []Example) float64 {
+	if len(examples) == 0 {
+		return 0
+	}
+	correct := 0
+	for _, ex := range examples {
+		if m.Predict(ex.Message)[0].LabelID == ex.LabelID {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(examples))
+}
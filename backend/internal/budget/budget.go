@@ -0,0 +1,237 @@
+// Package budget limits token spend with a per-minute token bucket and a
+// daily allowance, both globally and per log source.
+//
+// Calls reserve their estimated tokens up front and settle the difference
+// once the real usage is known, so a bucket may briefly go into debt; the
+// next calls wait for it to refill.
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrExhausted is wrapped by every *ExhaustedError.
+var ErrExhausted = errors.New("token budget exhausted")
+
+// ExhaustedError reports which limit refused a reservation. Scope is
+// "global" or "source", Window is "minute" or "day".
+type ExhaustedError struct {
+	Scope  string
+	Source string
+	Window string
+}
+
+func (e *ExhaustedError) Error() string {
+	if e.Scope == "global" {
+		return fmt.Sprintf("token budget exhausted: global per-%s limit", e.Window)
+	}
+	return fmt.Sprintf("token budget exhausted: source %q per-%s limit", e.Source, e.Window)
+}
+
+func (e *ExhaustedError) Unwrap() error { return ErrExhausted }
+
+// Limits are token allowances. Zero means unlimited.
+type Limits struct {
+	PerMinute int
+	PerDay    int
+}
+
+func (l Limits) unlimited() bool {
+	return l.PerMinute <= 0 && l.PerDay <= 0
+}
+
+// meter tracks one set of limits. The bucket holds up to PerMinute tokens
+// and refills at PerMinute per minute; the daily count resets at midnight
+// UTC.
+type meter struct {
+	limits Limits
+	tokens float64
+	filled time.Time
+	day    time.Time
+	used   int
+}
+
+func newMeter(l Limits, now time.Time) *meter {
+	return &meter{limits: l, tokens: float64(l.PerMinute), filled: now, day: startOfDay(now)}
+}
+
+func (m *meter) refill(now time.Time) {
+	if m.limits.PerMinute > 0 {
+		rate := float64(m.limits.PerMinute) / float64(time.Minute)
+		m.tokens = min(m.tokens+rate*float64(now.Sub(m.filled)), float64(m.limits.PerMinute))
+	}
+	m.filled = now
+	if d := startOfDay(now); d.After(m.day) {
+		m.day, m.used = d, 0
+	}
+}
+
+// refuses returns the window that cannot cover n tokens, or "". A request
+// larger than the whole bucket only needs a full bucket, so that it is
+// not refused forever.
+func (m *meter) refuses(n int) string {
+	if m.limits.PerMinute > 0 && m.tokens < float64(min(n, m.limits.PerMinute)) {
+		return "minute"
+	}
+	if m.limits.PerDay > 0 && m.used+n > m.limits.PerDay {
+		return "day"
+	}
+	return ""
+}
+
+func (m *meter) spend(n int) {
+	m.tokens -= float64(n)
+	m.used += n
+}
+
+// MaxSources bounds the meters kept for individual sources, since source
+// names come from clients. Meters that are full and unused today are
+// dropped to make room, which changes nothing about the limits they
+// enforce; beyond that, new sources share one overflow meter.
+const MaxSources = 1000
+
+// Budget is safe for concurrent use. A nil *Budget allows everything.
+type Budget struct {
+	global     Limits
+	perSource  Limits
+	overrides  map[string]Limits
+	maxSources int
+
+	mu       sync.Mutex
+	all      *meter
+	sources  map[string]*meter
+	overflow *meter
+	swept    time.Time
+	now      func() time.Time
+}
+
+// New returns a budget with global limits, default limits for every
+// source and per-source overrides.
+func New(global, perSource Limits, overrides map[string]Limits) *Budget {
+	return &Budget{
+		global:     global,
+		perSource:  perSource,
+		overrides:  overrides,
+		maxSources: MaxSources,
+		sources:    make(map[string]*meter),
+		now:        time.Now,
+	}
+}
+
+// meters returns the refilled global and source meters; either is nil
+// when unlimited.
+func (b *Budget) meters(source string) (global, src *meter) {
+	now := b.now()
+	if !b.global.unlimited() {
+		if b.all == nil {
+			b.all = newMeter(b.global, now)
+		}
+		global = b.all
+		global.refill(now)
+	}
+
+	l, ok := b.overrides[source]
+	if !ok {
+		l = b.perSource
+	}
+	if l.unlimited() {
+		return global, nil
+	}
+	src, ok = b.sources[source]
+	if !ok {
+		if _, overridden := b.overrides[source]; !overridden && len(b.sources) >= b.maxSources {
+			b.sweep(now)
+		}
+		if _, overridden := b.overrides[source]; overridden || len(b.sources) < b.maxSources {
+			src = newMeter(l, now)
+			b.sources[source] = src
+		} else {
+			if b.overflow == nil {
+				b.overflow = newMeter(b.perSource, now)
+			}
+			src = b.overflow
+		}
+	}
+	src.refill(now)
+	return global, src
+}
+
+// sweep drops the source meters that are indistinguishable from new
+// ones: bucket full and nothing spent today. It runs at most once a
+// minute, so a flood of new sources cannot make every call scan them.
+func (b *Budget) sweep(now time.Time) {
+	if now.Sub(b.swept) < time.Minute {
+		return
+	}
+	b.swept = now
+	for source, m := range b.sources {
+		m.refill(now)
+		if m.used == 0 && (m.limits.PerMinute <= 0 || m.tokens >= float64(m.limits.PerMinute)) {
+			delete(b.sources, source)
+		}
+	}
+}
+
+// Reserve takes tokens from the global and the source budget, or from
+// neither if either refuses; the error is then an *ExhaustedError.
+func (b *Budget) Reserve(source string, tokens int) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	global, src := b.meters(source)
+	if global != nil {
+		if w := global.refuses(tokens); w != "" {
+			return &ExhaustedError{Scope: "global", Window: w}
+		}
+	}
+	if src != nil {
+		if w := src.refuses(tokens); w != "" {
+			return &ExhaustedError{Scope: "source", Source: source, Window: w}
+		}
+	}
+	if global != nil {
+		global.spend(tokens)
+	}
+	if src != nil {
+		src.spend(tokens)
+	}
+	return nil
+}
+
+// Settle charges the difference between the tokens a call used and the
+// tokens reserved for it. A negative delta gives tokens back.
+func (b *Budget) Settle(source string, delta int) {
+	if b == nil || delta == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	global, src := b.meters(source)
+	if global != nil {
+		global.spend(delta)
+	}
+	if src != nil {
+		src.spend(delta)
+	}
+}
+
+// Overridden reports whether source has its own limits. Only those
+// sources get their own metric series.
+func (b *Budget) Overridden(source string) bool {
+	if b == nil {
+		return false
+	}
+	_, ok := b.overrides[source]
+	return ok
+}
+
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,130 @@
+package budget
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func withClock(b *Budget, now *time.Time) *Budget {
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestBudget_PerMinuteBucketRefills(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := withClock(New(Limits{PerMinute: 600}, Limits{}, nil), &now)
+
+	if err := b.Reserve("api", 500); err != nil {
+		t.Fatalf("first reservation: %v", err)
+	}
+	err := b.Reserve("api", 200)
+	var ex *ExhaustedError
+	if !errors.As(err, &ex) || ex.Scope != "global" || ex.Window != "minute" || !errors.Is(err, ErrExhausted) {
+		t.Fatalf("expected the global minute limit, got %v", err)
+	}
+
+	now = now.Add(10 * time.Second) // refills 100
+	if err := b.Reserve("api", 200); err != nil {
+		t.Fatalf("expected the bucket to have refilled: %v", err)
+	}
+}
+
+func TestBudget_PerSourceAndOverrides(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := withClock(New(Limits{}, Limits{PerDay: 100}, map[string]Limits{"payments": {PerDay: 1000}}), &now)
+
+	if err := b.Reserve("web", 80); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	err := b.Reserve("web", 80)
+	var ex *ExhaustedError
+	if !errors.As(err, &ex) || ex.Scope != "source" || ex.Source != "web" || ex.Window != "day" {
+		t.Fatalf("expected the web daily limit, got %v", err)
+	}
+	if err := b.Reserve("worker", 80); err != nil {
+		t.Fatalf("other sources have their own budget: %v", err)
+	}
+	if err := b.Reserve("payments", 500); err != nil {
+		t.Fatalf("payments has a larger budget: %v", err)
+	}
+
+	now = now.Add(12 * time.Hour) // past midnight UTC
+	if err := b.Reserve("web", 80); err != nil {
+		t.Fatalf("expected the daily count to reset: %v", err)
+	}
+}
+
+func TestBudget_RefusedReservationTakesNothing(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := withClock(New(Limits{PerDay: 150}, Limits{PerDay: 100}, nil), &now)
+
+	if err := b.Reserve("web", 120); err == nil {
+		t.Fatalf("expected the source limit to refuse")
+	}
+	if err := b.Reserve("worker", 100); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	if err := b.Reserve("web", 50); err != nil {
+		t.Fatalf("the refused reservation must not spend the global budget: %v", err)
+	}
+}
+
+func TestBudget_SettleChargesActualUsage(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := withClock(New(Limits{PerDay: 100}, Limits{}, nil), &now)
+
+	if err := b.Reserve("", 50); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	b.Settle("", 40) // the call used 90
+	if err := b.Reserve("", 20); err == nil {
+		t.Fatalf("expected the settled usage to count")
+	}
+	b.Settle("", -30)
+	if err := b.Reserve("", 20); err != nil {
+		t.Fatalf("expected refunded tokens to be available: %v", err)
+	}
+}
+
+func TestBudget_NilAllowsEverything(t *testing.T) {
+	var b *Budget
+	if err := b.Reserve("web", 1<<30); err != nil {
+		t.Fatalf("nil budget refused: %v", err)
+	}
+	b.Settle("web", 10)
+}
+
+func TestBudget_BoundsSourceMeters(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := withClock(New(Limits{}, Limits{PerDay: 100}, map[string]Limits{"payments": {PerDay: 100}}), &now)
+	b.maxSources = 2
+
+	for _, src := range []string{"a", "b"} {
+		if err := b.Reserve(src, 60); err != nil {
+			t.Fatalf("reserve %s: %v", src, err)
+		}
+	}
+	// new sources beyond the cap share the overflow meter
+	if err := b.Reserve("c", 60); err != nil {
+		t.Fatalf("reserve c: %v", err)
+	}
+	if err := b.Reserve("d", 60); err == nil {
+		t.Fatalf("expected d to share c's overflow budget")
+	}
+	if err := b.Reserve("payments", 60); err != nil {
+		t.Fatalf("overridden sources always get their own meter: %v", err)
+	}
+	if len(b.sources) != 3 {
+		t.Fatalf("expected 3 meters, got %d", len(b.sources))
+	}
+
+	// the next day a and b are idle and full, so they make room
+	now = now.Add(24 * time.Hour)
+	if err := b.Reserve("e", 60); err != nil {
+		t.Fatalf("reserve e: %v", err)
+	}
+	if _, ok := b.sources["e"]; !ok || len(b.sources) != 1 {
+		t.Fatalf("expected idle meters to be swept, got %d meters", len(b.sources))
+	}
+}
@@ -0,0 +1,211 @@
+package calibration
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// Calibrator maps a raw stage confidence onto a calibrated probability.
+type Calibrator interface {
+	Calibrate(score float64) float64
+}
+
+// Sample is one labeled observation: the raw score a stage reported and
+// whether its label turned out to be correct.
+type Sample struct {
+	Score   float64
+	Correct bool
+}
+
+// Platt is a sigmoid fitted to the raw scores: p = 1 / (1 + exp(A*s + B)).
+type Platt struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+}
+
+func (p Platt) Calibrate(score float64) float64 {
+	return 1 / (1 + math.Exp(p.A*score+p.B))
+}
+
+// Point is one step of an isotonic table.
+type Point struct {
+	Score       float64 `json:"score"`
+	Probability float64 `json:"probability"`
+}
+
+// Isotonic is a monotonic step table. Scores between points are linearly
+// interpolated and scores outside the table are clamped.
+type Isotonic struct {
+	Points []Point `json:"points"`
+}
+
+func (t Isotonic) Calibrate(score float64) float64 {
+	pts := t.Points
+	if len(pts) == 0 {
+		return score
+	}
+	if score <= pts[0].Score {
+		return pts[0].Probability
+	}
+	if score >= pts[len(pts)-1].Score {
+		return pts[len(pts)-1].Probability
+	}
+
+	i := sort.Search(len(pts), func(i int) bool { return pts[i].Score >= score })
+	lo, hi := pts[i-1], pts[i]
+	if hi.Score == lo.Score {
+		return hi.Probability
+	}
+	frac := (score - lo.Score) / (hi.Score - lo.Score)
+	return lo.Probability + frac*(hi.Probability-lo.Probability)
+}
+
+// FitPlatt fits a Platt sigmoid with Newton's method, using Platt's
+// smoothed targets to avoid overfitting small datasets.
+func FitPlatt(samples []Sample) (Platt, error) {
+	var pos, neg float64
+	for _, s := range samples {
+		if s.Correct {
+			pos++
+		} else {
+			neg++
+		}
+	}
+	if pos == 0 || neg == 0 {
+		return Platt{}, fmt.Errorf("platt: need both correct and incorrect samples, got %d/%d", int(pos), int(neg))
+	}
+
+	hiTarget := (pos + 1) / (pos + 2)
+	loTarget := 1 / (neg + 2)
+
+	a, b := 0.0, math.Log((neg+1)/(pos+1))
+	for iter := 0; iter < 100; iter++ {
+		// gradient and Hessian of the log loss
+		var g1, g2, h11, h22, h21 float64
+		for _, s := range samples {
+			t := loTarget
+			if s.Correct {
+				t = hiTarget
+			}
+			p := Platt{A: a, B: b}.Calibrate(s.Score)
+			d := t - p
+			w := p * (1 - p)
+			g1 += s.Score * d
+			g2 += d
+			h11 += s.Score * s.Score * w
+			h22 += w
+			h21 += s.Score * w
+		}
+		h11 += 1e-12
+		h22 += 1e-12
+
+		det := h11*h22 - h21*h21
+		if det == 0 {
+			break
+		}
+		da := -(h22*g1 - h21*g2) / det
+		db := -(-h21*g1 + h11*g2) / det
+		a += da
+		b += db
+		if math.Abs(da) < 1e-9 && math.Abs(db) < 1e-9 {
+			break
+		}
+	}
+	return Platt{A: a, B: b}, nil
+}
+
+// FitIsotonic fits a monotonic table with pool-adjacent-violators.
+func FitIsotonic(samples []Sample) (Isotonic, error) {
+	if len(samples) == 0 {
+		return Isotonic{}, fmt.Errorf("isotonic: no samples")
+	}
+
+	sorted := append([]Sample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Score < sorted[j].Score })
+
+	type block struct {
+		sum, weight, lo, hi float64
+	}
+	var blocks []block
+	for _, s := range sorted {
+		y := 0.0
+		if s.Correct {
+			y = 1
+		}
+		blocks = append(blocks, block{sum: y, weight: 1, lo: s.Score, hi: s.Score})
+		for len(blocks) > 1 {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			if prev.sum/prev.weight <= last.sum/last.weight {
+				break
+			}
+			blocks = blocks[:len(blocks)-2]
+			blocks = append(blocks, block{sum: prev.sum + last.sum, weight: prev.weight + last.weight, lo: prev.lo, hi: last.hi})
+		}
+	}
+
+	var t Isotonic
+	for _, b := range blocks {
+		p := b.sum / b.weight
+		t.Points = append(t.Points, Point{Score: b.lo, Probability: p})
+		if b.hi != b.lo {
+			t.Points = append(t.Points, Point{Score: b.hi, Probability: p})
+		}
+	}
+	return t, nil
+}
+
+// file is the on-disk format written by the calibrate command.
+type file struct {
+	Method string   `json:"method"`
+	Platt  *Platt   `json:"platt,omitempty"`
+	Table  Isotonic `json:"isotonic,omitempty"`
+}
+
+// Save writes a fitted calibrator to path.
+func Save(path string, c Calibrator) error {
+	var f file
+	switch c := c.(type) {
+	case Platt:
+		f = file{Method: "platt", Platt: &c}
+	case Isotonic:
+		f = file{Method: "isotonic", Table: c}
+	default:
+		return fmt.Errorf("calibration: unsupported calibrator %T", c)
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Load reads a calibrator written by Save.
+func Load(path string) (Calibrator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calibration: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse calibration %s: %w", path, err)
+	}
+
+	switch f.Method {
+	case "platt":
+		if f.Platt == nil {
+			return nil, fmt.Errorf("calibration %s: missing platt parameters", path)
+		}
+		return *f.Platt, nil
+	case "isotonic":
+		if len(f.Table.Points) == 0 {
+			return nil, fmt.Errorf("calibration %s: empty isotonic table", path)
+		}
+		return f.Table, nil
+	}
+	return nil, fmt.Errorf("calibration %s: unknown method %q", path, f.Method)
+}
@@ -0,0 +1,70 @@
+package calibration
+
+import (
+	"math/rand/v2"
+	"path/filepath"
+	"testing"
+)
+
+// samples draws scores whose true accuracy is score^2, so raw scores are
+// overconfident in the middle of the range.
+func samples(n int) []Sample {
+	r := rand.New(rand.NewPCG(1, 2))
+	out := make([]Sample, n)
+	for i := range out {
+		s := r.Float64()
+		out[i] = Sample{Score: s, Correct: r.Float64() < s*s}
+	}
+	return out
+}
+
+func TestFitPlatt_IsMonotonicAndCalibrated(t *testing.T) {
+	p, err := FitPlatt(samples(5000))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.A >= 0 {
+		t.Fatalf("expected a negative slope, got A=%v", p.A)
+	}
+
+	if lo, hi := p.Calibrate(0.2), p.Calibrate(0.9); lo >= hi {
+		t.Fatalf("calibration is not increasing: %v >= %v", lo, hi)
+	}
+	if got := p.Calibrate(0.5); got < 0.15 || got > 0.4 {
+		t.Fatalf("expected ~0.25 at score 0.5, got %v", got)
+	}
+}
+
+func TestFitIsotonic_IsMonotonic(t *testing.T) {
+	table, err := FitIsotonic(samples(2000))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	prev := -1.0
+	for _, pt := range table.Points {
+		if pt.Probability < prev {
+			t.Fatalf("table is not monotonic at score %v", pt.Score)
+		}
+		prev = pt.Probability
+	}
+	if got := table.Calibrate(0.5); got < 0.1 || got > 0.45 {
+		t.Fatalf("expected ~0.25 at score 0.5, got %v", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bert.json")
+	want := Platt{A: -5, B: 2.5}
+
+	if err := Save(path, want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got.Calibrate(0.7) != want.Calibrate(0.7) {
+		t.Fatalf("loaded calibrator differs: %+v", got)
+	}
+}
@@ -0,0 +1,78 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"log-classifier/internal/bayes"
+	"log-classifier/internal/config"
+	"log-classifier/internal/models"
+	"log-classifier/internal/taxonomy"
+	"slices"
+)
+
+var (
+	// bayesModel is nil unless a trained model is configured, in which
+	// case the bayes stage runs between regex and BERT.
+	bayesModel *bayes.Model
+	bayesTopK  = 3
+)
+
+// ConfigureBayes loads the model for the bayes stage. Every label it
+// was trained on must be in the taxonomy. It must be called before the
+// server starts handling requests.
+func ConfigureBayes(cfg config.BayesConfig) error {
+	var m *bayes.Model
+	if cfg.ModelFile != "" {
+		var err error
+		if m, err = bayes.Load(cfg.ModelFile); err != nil {
+			return fmt.Errorf("bayes: %w", err)
+		}
+		for _, id := range m.Labels {
+			if _, ok := taxonomy.Current().Lookup(id); !ok {
+				return fmt.Errorf("bayes: %s: model label %q is not in the taxonomy", cfg.ModelFile, id)
+			}
+		}
+	}
+	bayesModel = m
+	bayesTopK = cfg.TopK
+	primaryPipeline = newDefaultPipeline()
+	return nil
+}
+
+// bayesStage answers with the model's most likely label and returns the
+// top k labels with their probabilities. The others among the top k are
+// also its secondary labels for multi-label results.
+type bayesStage struct{}
+
+func (bayesStage) Name() string { return "bayes" }
+
+func (bayesStage) Classify(_ context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	m := bayesModel
+	if m == nil {
+		return nil, nil
+	}
+
+	preds := m.Predict(entry.LogMessage)
+	topK := make([]models.LabelScore, 0, min(bayesTopK, len(preds)))
+	for _, p := range preds[:cap(topK)] {
+		topK = append(topK, models.LabelScore{
+			LabelID:    p.LabelID,
+			Label:      taxonomy.Current().Name(p.LabelID),
+			Confidence: p.Probability,
+		})
+	}
+	return &models.ClassificationResult{
+		LabelID:    preds[0].LabelID,
+		Classifier: "bayes",
+		Confidence: preds[0].Probability,
+		TopK:       topK,
+		Labels:     slices.Clone(topK[1:]),
+	}, nil
+}
+
+// StageInput returns the message the named stage sees for msg, after
+// redaction and normalization. The train command uses it so that a
+// model learns from the same input it is served.
+func StageInput(stage, msg string) string {
+	return stageEntry(context.Background(), stage, models.LogEntry{LogMessage: msg}).LogMessage
+}
@@ -0,0 +1,68 @@
+package classifier
+
+import (
+	"log-classifier/internal/bayes"
+	"log-classifier/internal/config"
+	"log-classifier/internal/models"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useBayesModel(t *testing.T, examples []bayes.Example) string {
+	t.Helper()
+	m, err := bayes.Train(examples, bayes.Config{Buckets: 1 << 12})
+	if err != nil {
+		t.Fatalf("train: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "bayes.json")
+	if err := bayes.Save(path, m); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	return path
+}
+
+func TestBayesStage_BetweenRegexAndBERT(t *testing.T) {
+	path := useBayesModel(t, []bayes.Example{
+		{Message: "connection to database refused", LabelID: "DB_ERROR"},
+		{Message: "deadlock detected on table orders", LabelID: "DB_ERROR"},
+		{Message: "escalation rule execution failed", LabelID: "WORKFLOW_ERROR"},
+		{Message: "workflow step timed out", LabelID: "WORKFLOW_ERROR"},
+		{Message: "user changed password", LabelID: "USER_ACTION"},
+	})
+	if err := ConfigureBayes(config.BayesConfig{ModelFile: path, TopK: 2}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureBayes(config.Default().Bayes)
+
+	var names []string
+	for _, s := range primaryPipeline.steps {
+		names = append(names, s.stage.Name())
+	}
+	if got := strings.Join(names, ","); got != "regex,bayes,bert,llm" {
+		t.Fatalf("unexpected pipeline %s", got)
+	}
+
+	s, _ := stageByName("bayes")
+	run := s.run(t.Context(), models.LogEntry{LogMessage: "Deadlock detected on table invoices"})
+	r := run.result
+	if run.err != nil || r.LabelID != "DB_ERROR" || r.Label != "Database Error" || r.Classifier != "bayes" {
+		t.Fatalf("unexpected result %+v, %v", r, run.err)
+	}
+	if len(r.TopK) != 2 || r.TopK[0].LabelID != "DB_ERROR" || r.TopK[0].Confidence != r.Confidence || r.TopK[1].Confidence > r.Confidence {
+		t.Fatalf("unexpected top k %+v", r.TopK)
+	}
+	if len(r.Labels) != 1 || r.Labels[0].LabelID != r.TopK[1].LabelID {
+		t.Fatalf("expected the runner-up as secondary label, got %+v", r.Labels)
+	}
+}
+
+func TestConfigureBayes_RejectsUnknownLabels(t *testing.T) {
+	path := useBayesModel(t, []bayes.Example{
+		{Message: "a", LabelID: "DB_ERROR"},
+		{Message: "b", LabelID: "NOT_A_LABEL"},
+	})
+	if err := ConfigureBayes(config.BayesConfig{ModelFile: path, TopK: 3}); err == nil || !strings.Contains(err.Error(), "NOT_A_LABEL") {
+		t.Fatalf("expected the unknown label to be rejected, got %v", err)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log-classifier/internal/metrics"
 	"log-classifier/internal/models"
+	"log-classifier/internal/taxonomy"
 	"net/http"
 	"time"
 )
@@ -16,18 +18,48 @@ type BERTRequest struct {
 }
 
 type BERTResponse struct {
-	LabelID    string  `json:"label_id"`
-	Label      string  `json:"label"`
-	Confidence float64 `json:"confidence"`
+	LabelID    string              `json:"label_id"`
+	Label      string              `json:"label"`
+	Confidence float64             `json:"confidence"`
+	Labels     []models.LabelScore `json:"labels,omitempty"`
 }
 
 var bertClient = &http.Client{
 	Timeout: 5 * time.Second,
 }
 
-const bertServiceURL = "http://127.0.0.1:5000/classify"
+const defaultBERTServiceURL = "http://127.0.0.1:5000/classify"
+
+// unknownBERTLabel is the metric label for label IDs the taxonomy does not
+// know, which keeps the series count bounded whatever the service returns.
+const unknownBERTLabel = "unknown"
 
 func ClassifyWithBERT(ctx context.Context, msg string) (*models.ClassificationResult, error) {
+	variant := bertRoutes.pick(msg)
+	start := time.Now()
+
+	result, err := CallWithBreaker(breakerFor(ctx, bertBreaker), func() (*models.ClassificationResult, error) {
+		return callBERT(ctx, variant.url, msg)
+	})
+
+	label := metricName(ctx, variant.name)
+	metrics.BERTVariantDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.BERTVariantRequests.WithLabelValues(label, "error").Inc()
+		return nil, err
+	}
+	metrics.BERTVariantRequests.WithLabelValues(label, "success").Inc()
+	labelID := result.LabelID
+	if _, ok := taxonomy.Current().Lookup(labelID); !ok {
+		labelID = unknownBERTLabel
+	}
+	metrics.BERTVariantLabels.WithLabelValues(label, labelID).Inc()
+
+	result.ModelVariant = variant.name
+	return result, nil
+}
+
+func callBERT(ctx context.Context, url, msg string) (*models.ClassificationResult, error) {
 	fmt.Println("DEBUG: BERT CALLED with:", msg)
 	reqBody := BERTRequest{Message: msg}
 	jsonData, err := json.Marshal(reqBody)
@@ -36,7 +68,7 @@ func ClassifyWithBERT(ctx context.Context, msg string) (*models.ClassificationRe
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", bertServiceURL, bytes.NewBuffer((jsonData)))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer((jsonData)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -70,6 +102,7 @@ func ClassifyWithBERT(ctx context.Context, msg string) (*models.ClassificationRe
 		Label:      bertResp.Label,
 		Classifier: "classifier",
 		Confidence: bertResp.Confidence,
+		Labels:     bertResp.Labels,
 	}, nil
 
 }
@@ -0,0 +1,102 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"log-classifier/internal/budget"
+	"log-classifier/internal/config"
+	"log-classifier/internal/llm"
+	"log-classifier/internal/metrics"
+)
+
+// serviceOverheadTokens estimates the prompt the Python LLM service wraps
+// around each message, which it does not report.
+const serviceOverheadTokens = 200
+
+// otherSource is the metric label for sources without their own budget,
+// which keeps the series count bounded. Shadow calls are counted under
+// shadowSource.
+const (
+	otherSource  = "other"
+	shadowSource = "shadow"
+)
+
+var (
+	// llmBudget is nil when no token limits are configured.
+	llmBudget *budget.Budget
+
+	promptPrice, completionPrice float64
+)
+
+func configureLLMBudget(cfg config.LLMBudget) {
+	promptPrice = cfg.PromptPrice
+	completionPrice = cfg.CompletionPrice
+
+	overrides := make(map[string]budget.Limits, len(cfg.Sources))
+	for src, l := range cfg.Sources {
+		overrides[src] = limits(l)
+	}
+	if cfg.TokensPerMinute == 0 && cfg.TokensPerDay == 0 &&
+		cfg.PerSource.TokensPerMinute == 0 && cfg.PerSource.TokensPerDay == 0 && len(overrides) == 0 {
+		llmBudget = nil
+		return
+	}
+	llmBudget = budget.New(limits(cfg.TokenLimits), limits(cfg.PerSource), overrides)
+}
+
+func limits(l config.TokenLimits) budget.Limits {
+	return budget.Limits{PerMinute: l.TokensPerMinute, PerDay: l.TokensPerDay}
+}
+
+// ledger returns the budget a call is charged to. Shadow calls are not
+// charged, so that sampled comparisons cannot starve live traffic.
+func ledger(ctx context.Context) *budget.Budget {
+	if isShadow(ctx) {
+		return nil
+	}
+	return llmBudget
+}
+
+// reserveLLM takes the estimated tokens for a call from the budget.
+func reserveLLM(ctx context.Context, source string, tokens int) error {
+	err := ledger(ctx).Reserve(source, tokens)
+	var ex *budget.ExhaustedError
+	if errors.As(err, &ex) {
+		metrics.LLMBudgetExhausted.WithLabelValues(ex.Scope, ex.Window).Inc()
+	}
+	return err
+}
+
+// settleLLM charges the tokens a call used against its reservation. An
+// empty usage keeps the estimate. A call that failed before the backend
+// could have done any work gets its reservation back.
+func settleLLM(ctx context.Context, source string, reserved int, usage llm.Usage, err error) {
+	b := ledger(ctx)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		b.Settle(source, -reserved)
+		return
+	}
+
+	label := source
+	switch {
+	case isShadow(ctx):
+		label = shadowSource
+	case !b.Overridden(source):
+		label = otherSource
+	}
+	if usage.TotalTokens == 0 && usage.PromptTokens == 0 {
+		metrics.LLMTokens.WithLabelValues(label, "estimated").Add(float64(reserved))
+		metrics.LLMSpend.WithLabelValues(label).Add(float64(reserved) * promptPrice / 1e6)
+		return
+	}
+
+	used := usage.TotalTokens
+	if used == 0 {
+		used = usage.PromptTokens + usage.CompletionTokens
+	}
+	b.Settle(source, used-reserved)
+	metrics.LLMTokens.WithLabelValues(label, "prompt").Add(float64(usage.PromptTokens))
+	metrics.LLMTokens.WithLabelValues(label, "completion").Add(float64(usage.CompletionTokens))
+	metrics.LLMSpend.WithLabelValues(label).Add(
+		(float64(usage.PromptTokens)*promptPrice + float64(usage.CompletionTokens)*completionPrice) / 1e6)
+}
@@ -0,0 +1,69 @@
+package classifier
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	variantStable = "stable"
+	variantCanary = "canary"
+)
+
+type bertVariant struct {
+	name string
+	url  string
+}
+
+// bertRouter splits BERT traffic between a stable and a canary deployment.
+// Routing is sticky by message hash, so the same message always hits the
+// same variant for a given percentage.
+type bertRouter struct {
+	mu      sync.RWMutex
+	stable  bertVariant
+	canary  bertVariant
+	percent int
+}
+
+func newBERTRouter(stableURL string) *bertRouter {
+	return &bertRouter{
+		stable: bertVariant{name: variantStable, url: stableURL},
+		canary: bertVariant{name: variantCanary},
+	}
+}
+
+func (r *bertRouter) set(stableURL, canaryURL string, percent int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stable.url = stableURL
+	r.canary.url = canaryURL
+	r.percent = percent
+}
+
+func (r *bertRouter) pick(msg string) bertVariant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.percent <= 0 || r.canary.url == "" {
+		return r.stable
+	}
+	if bucket(msg) < uint32(r.percent) {
+		return r.canary
+	}
+	return r.stable
+}
+
+// bucket maps a message onto 0-99.
+func bucket(msg string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(msg))
+	return h.Sum32() % 100
+}
+
+var bertRoutes = newBERTRouter(defaultBERTServiceURL)
+
+// ConfigureBERTCanary points the BERT stage at a stable endpoint and,
+// optionally, sends percent of the traffic to a canary endpoint.
+func ConfigureBERTCanary(stableURL, canaryURL string, percent int) {
+	bertRoutes.set(stableURL, canaryURL, percent)
+}
@@ -0,0 +1,70 @@
+package classifier
+
+import (
+	"fmt"
+	"log-classifier/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBERTRouter_StableOnlyWithoutCanary(t *testing.T) {
+	r := newBERTRouter("http://stable")
+
+	for i := 0; i < 100; i++ {
+		if v := r.pick(fmt.Sprintf("msg-%d", i)); v.name != variantStable {
+			t.Fatalf("expected stable, got %s", v.name)
+		}
+	}
+}
+
+func TestBERTRouter_StickyAndWeighted(t *testing.T) {
+	r := newBERTRouter("http://stable")
+	r.set("http://stable", "http://canary", 20)
+
+	canary := 0
+	for i := 0; i < 5000; i++ {
+		msg := fmt.Sprintf("msg-%d", i)
+		first := r.pick(msg)
+		if again := r.pick(msg); again.name != first.name {
+			t.Fatalf("routing for %q is not sticky", msg)
+		}
+		if first.name == variantCanary {
+			canary++
+		}
+	}
+
+	// 20% of 5000 with some slack for hash skew
+	if canary < 800 || canary > 1200 {
+		t.Fatalf("expected ~1000 canary routes, got %d", canary)
+	}
+}
+
+func TestClassifyWithBERT_BucketsUnknownLabels(t *testing.T) {
+	labelID := "INFO"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"label_id": %q, "confidence": 0.9}`, labelID)
+	}))
+	defer srv.Close()
+	ConfigureBERTCanary(srv.URL, "", 0)
+	defer ConfigureBERTCanary(defaultBERTServiceURL, "", 0)
+
+	known := testutil.ToFloat64(metrics.BERTVariantLabels.WithLabelValues(variantStable, "INFO"))
+	unknown := testutil.ToFloat64(metrics.BERTVariantLabels.WithLabelValues(variantStable, unknownBERTLabel))
+
+	for _, id := range []string{"INFO", "made-up-1", "made-up-2"} {
+		labelID = id
+		if _, err := ClassifyWithBERT(t.Context(), "x"); err != nil {
+			t.Fatalf("classify: %v", err)
+		}
+	}
+
+	if got := testutil.ToFloat64(metrics.BERTVariantLabels.WithLabelValues(variantStable, "INFO")) - known; got != 1 {
+		t.Fatalf("expected INFO to be counted once, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.BERTVariantLabels.WithLabelValues(variantStable, unknownBERTLabel)) - unknown; got != 2 {
+		t.Fatalf("expected unknown label IDs to share one series, got %v", got)
+	}
+}
@@ -0,0 +1,216 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log-classifier/internal/budget"
+	"log-classifier/internal/config"
+	"log-classifier/internal/llm"
+	"log-classifier/internal/metrics"
+	"log-classifier/internal/models"
+	"log-classifier/internal/taxonomy"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// chatLLM holds the chat completions providers in fallback order. It
+	// is empty when the LLM stage calls the Python LLM service instead.
+	chatLLM []*chatClassifier
+
+	llmTimeout = 2 * time.Second
+)
+
+// ConfigureLLM chooses the LLM stage backend. It must be called before
+// the server starts handling requests, and before the other pipelines
+// are configured so that they use its timeout.
+func ConfigureLLM(cfg config.LLMConfig) error {
+	llmTimeout = cfg.Timeout
+	configureLLMBudget(cfg.Budget)
+	defer func() { primaryPipeline = newDefaultPipeline() }()
+
+	providers := cfg.ChatProviders()
+	chain := make([]*chatClassifier, 0, len(providers))
+	var timeout time.Duration
+	for _, p := range providers {
+		// a single provider keeps the stage's own breaker
+		cb := llmBreaker
+		if len(cfg.Providers) > 0 {
+			cb = NewCircuitBreaker("llm/"+p.Name, 3, 5*time.Second)
+		}
+		c, err := newChatClassifier(p, taxonomy.Current(), cb)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, c)
+		timeout += p.Timeout
+	}
+	chatLLM = chain
+	if len(chain) > 0 {
+		// the stage may have to wait for every provider in turn
+		llmTimeout = timeout
+	}
+	return nil
+}
+
+type chatClassifier struct {
+	name        string
+	timeout     time.Duration
+	breaker     *CircuitBreaker
+	client      *llm.Client
+	prompt      *llm.Prompt
+	model       string
+	temperature float64
+	maxTokens   int
+	format      *llm.ResponseFormat
+}
+
+func newChatClassifier(p config.LLMProvider, reg *taxonomy.Registry, cb *CircuitBreaker) (*chatClassifier, error) {
+	examples := make([]llm.Example, len(p.Examples))
+	for i, ex := range p.Examples {
+		examples[i] = llm.Example{Message: ex.Message, LabelID: ex.Label}
+	}
+	prompt, err := llm.NewPrompt(p.SystemPrompt, reg.Labels(), examples)
+	if err != nil {
+		return nil, fmt.Errorf("llm provider %s: %w", p.Name, err)
+	}
+
+	var apiKey string
+	if p.APIKeyEnv != "" {
+		apiKey = os.Getenv(p.APIKeyEnv)
+	}
+	return &chatClassifier{
+		name:        p.Name,
+		timeout:     p.Timeout,
+		breaker:     cb,
+		client:      &llm.Client{BaseURL: p.BaseURL, APIKey: apiKey, HTTP: &http.Client{}},
+		prompt:      prompt,
+		model:       p.Model,
+		temperature: *p.Temperature,
+		maxTokens:   p.MaxTokens,
+		format:      prompt.ResponseFormat(p.ResponseFormat),
+	}, nil
+}
+
+// classifyChain asks each provider in turn until one answers, and
+// records which one did. An exhausted token budget ends the chain, since
+// the providers share it.
+func classifyChain(ctx context.Context, chain []*chatClassifier, entry models.LogEntry) (*models.ClassificationResult, error) {
+	var failed []string
+	for i, c := range chain {
+		result, err := c.classify(ctx, entry)
+		if err == nil {
+			metrics.LLMProviderCalls.WithLabelValues(metricName(ctx, c.name), "answered").Inc()
+			result.LLMProvider = c.name
+			return result, nil
+		}
+		metrics.LLMProviderCalls.WithLabelValues(metricName(ctx, c.name), providerOutcome(err)).Inc()
+		if errors.Is(err, budget.ErrExhausted) || ctx.Err() != nil || i == len(chain)-1 {
+			if len(failed) == 0 {
+				return nil, err
+			}
+			// the last error decides whether the stage is retried
+			return nil, fmt.Errorf("%s; %s: %w", strings.Join(failed, "; "), c.name, err)
+		}
+		failed = append(failed, fmt.Sprintf("%s: %v", c.name, err))
+	}
+	return nil, nil
+}
+
+func providerOutcome(err error) string {
+	switch {
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrTooManyRequests):
+		return "circuit_open"
+	case errors.Is(err, budget.ErrExhausted):
+		return "budget_exhausted"
+	case errors.Is(err, llm.ErrInvalidAnswer):
+		return "invalid"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "error"
+	}
+}
+
+// complete sends one request, charging its tokens to source's budget.
+func (c *chatClassifier) complete(ctx context.Context, source string, msgs []llm.Message) (string, error) {
+	req := llm.ChatRequest{
+		Model:          c.model,
+		Messages:       msgs,
+		Temperature:    c.temperature,
+		MaxTokens:      c.maxTokens,
+		ResponseFormat: c.format,
+	}
+	reserved := llm.EstimateTokens(req)
+	if err := reserveLLM(ctx, source, reserved); err != nil {
+		return "", err
+	}
+	resp, err := CallWithBreaker(breakerFor(ctx, c.breaker), func() (*llm.ChatResponse, error) {
+		return c.client.Complete(ctx, req)
+	})
+	if err != nil {
+		settleLLM(ctx, source, reserved, llm.Usage{}, err)
+		return "", err
+	}
+	settleLLM(ctx, source, reserved, resp.Usage, nil)
+	return resp.Choices[0].Message.Content, nil
+}
+
+// classify asks the model for a label. An invalid answer is sent back
+// once with a description of what was wrong. Only the API call goes
+// through the breaker; an answer that does not validate is the model's
+// fault, not the service's.
+func (c *chatClassifier) classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	msgs := c.prompt.Messages(entry.LogMessage)
+	reply, err := c.complete(ctx, entry.Source, msgs)
+	if err != nil {
+		return nil, err
+	}
+
+	answer, err := c.prompt.Parse(reply)
+	if errors.Is(err, llm.ErrInvalidAnswer) {
+		countAnswer(ctx, "reasked")
+		reply, err = c.complete(ctx, entry.Source, c.prompt.Reask(msgs, reply, err))
+		if err != nil {
+			return nil, err
+		}
+		answer, err = c.prompt.Parse(reply)
+	}
+	if err != nil {
+		countAnswer(ctx, "invalid")
+		return nil, err
+	}
+	return answerResult(ctx, answer), nil
+}
+
+// answerResult converts a validated answer, counting the repairs it needed.
+func answerResult(ctx context.Context, a llm.Answer) *models.ClassificationResult {
+	if a.Extracted {
+		countAnswer(ctx, "extracted")
+	}
+	if a.CoercedFrom != "" {
+		countAnswer(ctx, "coerced")
+	}
+	if !a.Repaired() {
+		countAnswer(ctx, "valid")
+	}
+	return &models.ClassificationResult{
+		LabelID:    a.LabelID,
+		Classifier: "llm",
+		Confidence: a.Confidence,
+	}
+}
+
+// countAnswer counts a live answer by validation outcome. Shadow answers
+// are left out, since the metric has no stage to label them with.
+func countAnswer(ctx context.Context, outcome string) {
+	if !isShadow(ctx) {
+		metrics.LLMAnswers.WithLabelValues(outcome).Inc()
+	}
+}
@@ -0,0 +1,286 @@
+package classifier
+
+import (
+	"encoding/json"
+	"errors"
+	"log-classifier/internal/config"
+	"log-classifier/internal/llm"
+	"log-classifier/internal/metrics"
+	"log-classifier/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeChatServer stands in for an OpenAI-compatible server such as
+// llama.cpp or Ollama. It answers with replies in turn, repeating the
+// last one.
+func fakeChatServer(t *testing.T, requests *[]llm.ChatRequest, replies ...string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	n := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		mu.Lock()
+		if requests != nil {
+			*requests = append(*requests, req)
+		}
+		content := replies[min(n, len(replies)-1)]
+		n++
+		mu.Unlock()
+
+		json.NewEncoder(w).Encode(llm.ChatResponse{
+			Model:   req.Model,
+			Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: content}, FinishReason: "stop"}},
+			Usage:   llm.Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func chatConfig(url string) config.LLMConfig {
+	cfg := config.Default().LLM
+	cfg.BaseURL = url
+	cfg.Model = "llama3"
+	return cfg
+}
+
+func TestLLMStage_ChatCompletions(t *testing.T) {
+	var requests []llm.ChatRequest
+	srv := fakeChatServer(t, &requests, `{"label_id": "WORKFLOW_ERROR", "confidence": 0.82}`)
+
+	cfg := chatConfig(srv.URL)
+	cfg.Examples = []config.LLMExample{{Message: "Escalation rule failed", Label: "WORKFLOW_ERROR"}}
+	if err := ConfigureLLM(cfg); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureLLM(config.Default().LLM)
+
+	s, _ := stageByName("llm")
+	run := s.run(t.Context(), models.LogEntry{LogMessage: "Case escalation for ticket 7324 failed"})
+	if run.err != nil {
+		t.Fatalf("llm stage: %v", run.err)
+	}
+	if r := run.result; r.LabelID != "WORKFLOW_ERROR" || r.Label != "Workflow Error" || r.Classifier != "llm" || r.Confidence != 0.82 || r.LLMProvider != "default" {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+
+	req := requests[0]
+	if req.Model != "llama3" || len(req.Messages) != 4 || req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+}
+
+func TestLLMStage_ReasksOnceOnInvalidAnswer(t *testing.T) {
+	var requests []llm.ChatRequest
+	srv := fakeChatServer(t, &requests,
+		`{"label_id": "NETWORK_FAILURE", "confidence": 0.9}`,
+		`Sorry! {"label_id": "workflow error", "confidence": 0.75}`,
+	)
+	if err := ConfigureLLM(chatConfig(srv.URL)); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureLLM(config.Default().LLM)
+
+	s, _ := stageByName("llm")
+	run := s.run(t.Context(), models.LogEntry{LogMessage: "x"})
+	if run.err != nil || run.result.LabelID != "WORKFLOW_ERROR" || run.result.Confidence != 0.75 {
+		t.Fatalf("expected the re-asked answer, got %+v, %v", run.result, run.err)
+	}
+	if len(requests) != 2 || run.attempts != 1 {
+		t.Fatalf("expected one re-ask within one attempt, got %d requests in %d attempts", len(requests), run.attempts)
+	}
+	reask := requests[1].Messages
+	if last := reask[len(reask)-1]; last.Role != "user" || !strings.Contains(last.Content, `"NETWORK_FAILURE" is not one of`) {
+		t.Fatalf("unexpected re-ask message: %+v", last)
+	}
+}
+
+func TestLLMStage_FailsAfterReask(t *testing.T) {
+	var requests []llm.ChatRequest
+	srv := fakeChatServer(t, &requests, `The label is WORKFLOW_ERROR.`)
+	if err := ConfigureLLM(chatConfig(srv.URL)); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureLLM(config.Default().LLM)
+
+	s, _ := stageByName("llm")
+	run := s.run(t.Context(), models.LogEntry{LogMessage: "x"})
+	if !errors.Is(run.err, llm.ErrInvalidAnswer) || run.attempts != 2 || len(requests) != 4 {
+		t.Fatalf("expected an invalid answer after 2 attempts of 2 requests, got %v after %d attempts, %d requests", run.err, run.attempts, len(requests))
+	}
+	if llmBreaker.State() != StateClosed {
+		t.Fatalf("malformed answers must not open the breaker")
+	}
+}
+
+func TestServiceAnswer_ValidatesAndCoerces(t *testing.T) {
+	r, err := serviceAnswer(t.Context(), `{"label_id": "Database Error", "label": "x", "classifier": "llm", "confidence": 0.6}`)
+	if err != nil || r.LabelID != "DB_ERROR" || r.Confidence != 0.6 {
+		t.Fatalf("expected coerced DB_ERROR, got %+v, %v", r, err)
+	}
+	if _, err := serviceAnswer(t.Context(), `{"label_id": "DB_ERROR"}`); !errors.Is(err, llm.ErrInvalidAnswer) {
+		t.Fatalf("expected a missing confidence to be rejected, got %v", err)
+	}
+}
+
+func TestConfigureLLM_SetsTimeout(t *testing.T) {
+	cfg := config.Default().LLM
+	cfg.Timeout = 9 * time.Second
+	ConfigureLLM(cfg)
+	defer ConfigureLLM(config.Default().LLM)
+
+	if s, _ := stageByName("llm"); s.timeout != 9*time.Second {
+		t.Fatalf("expected 9s timeout, got %s", s.timeout)
+	}
+}
+
+func TestLLMStage_SkipsSourceOverBudget(t *testing.T) {
+	var requests []llm.ChatRequest
+	srv := fakeChatServer(t, &requests, `{"label_id": "WORKFLOW_ERROR", "confidence": 0.82}`)
+	cfg := chatConfig(srv.URL)
+	cfg.Budget.Sources = map[string]config.TokenLimits{"batch": {TokensPerDay: 1}}
+	cfg.Budget.PromptPrice = 2
+	cfg.Budget.CompletionPrice = 10
+	if err := ConfigureLLM(cfg); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureLLM(config.Default().LLM)
+
+	exhausted := testutil.ToFloat64(metrics.LLMBudgetExhausted.WithLabelValues("source", "day"))
+	prompt := testutil.ToFloat64(metrics.LLMTokens.WithLabelValues("other", "prompt"))
+	spend := testutil.ToFloat64(metrics.LLMSpend.WithLabelValues("other"))
+
+	llmStep, _ := stageByName("llm")
+	p := &Pipeline{steps: []step{llmStep}}
+
+	r := p.Classify(models.LogEntry{Source: "batch", LogMessage: "Case escalation failed"}, Options{Explain: true})
+	if r.LabelID != "UNCLASSIFIED" || len(r.Skipped) != 1 || r.Skipped[0].Stage != "llm" || !strings.Contains(r.Skipped[0].Reason, `source "batch" per-day limit`) {
+		t.Fatalf("expected the llm stage to be skipped, got %+v", r)
+	}
+	if len(requests) != 0 || r.Trace[0].Outcome != "skipped" {
+		t.Fatalf("expected no call and a skipped trace, got %d calls, %+v", len(requests), r.Trace)
+	}
+	if got := testutil.ToFloat64(metrics.LLMBudgetExhausted.WithLabelValues("source", "day")) - exhausted; got != 1 {
+		t.Fatalf("budget exhausted metric moved by %v", got)
+	}
+
+	r = p.Classify(models.LogEntry{Source: "web", LogMessage: "Case escalation failed"}, Options{})
+	if r.LabelID != "WORKFLOW_ERROR" || len(r.Skipped) != 0 {
+		t.Fatalf("other sources must not be limited, got %+v", r)
+	}
+	if got := testutil.ToFloat64(metrics.LLMTokens.WithLabelValues("other", "prompt")) - prompt; got != 100 {
+		t.Fatalf("expected the reported prompt tokens to be counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.LLMSpend.WithLabelValues("other")) - spend; got != (100*2+10*10)/1e6 {
+		t.Fatalf("unexpected spend %v", got)
+	}
+}
+
+func TestLLMStage_ShadowCallsAreNotCharged(t *testing.T) {
+	srv := fakeChatServer(t, nil, `{"label_id": "WORKFLOW_ERROR", "confidence": 0.82}`)
+	cfg := chatConfig(srv.URL)
+	cfg.Budget.TokensPerDay = 1
+	if err := ConfigureLLM(cfg); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureLLM(config.Default().LLM)
+
+	prompt := testutil.ToFloat64(metrics.LLMTokens.WithLabelValues("shadow", "prompt"))
+
+	llmStep, _ := stageByName("llm")
+	entry := models.LogEntry{Source: "web", LogMessage: "Case escalation failed"}
+	shadowPipeline := &Pipeline{steps: []step{llmStep}, shadow: true}
+	if r := shadowPipeline.Classify(entry, Options{}); r.LabelID != "WORKFLOW_ERROR" || len(r.Skipped) != 0 {
+		t.Fatalf("expected the shadow call to ignore the budget, got %+v", r)
+	}
+	if got := testutil.ToFloat64(metrics.LLMTokens.WithLabelValues("shadow", "prompt")) - prompt; got != 100 {
+		t.Fatalf("expected the shadow tokens to be counted apart, got %v", got)
+	}
+
+	live := &Pipeline{steps: []step{llmStep}}
+	if r := live.Classify(entry, Options{}); len(r.Skipped) != 1 {
+		t.Fatalf("expected live calls to stay limited, got %+v", r)
+	}
+}
+
+func TestLLMStage_FailsOverBetweenProviders(t *testing.T) {
+	hostedCalls := 0
+	hosted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostedCalls++
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer hosted.Close()
+	var requests []llm.ChatRequest
+	local := fakeChatServer(t, &requests, `{"label_id": "WORKFLOW_ERROR", "confidence": 0.7}`)
+
+	cfg := config.Default().LLM
+	cfg.Model = "llama-3.1-8b-instant"
+	cfg.Providers = []config.LLMProvider{
+		{Name: "hosted", BaseURL: hosted.URL},
+		{Name: "local", BaseURL: local.URL, Model: "llama3", SystemPrompt: "Pick one of:{{range .Labels}} {{.ID}}{{end}}"},
+	}
+	if err := ConfigureLLM(cfg); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureLLM(config.Default().LLM)
+
+	if llmTimeout != 2*cfg.Timeout {
+		t.Fatalf("expected the stage timeout to cover both providers, got %s", llmTimeout)
+	}
+
+	s, _ := stageByName("llm")
+	for range 4 {
+		run := s.run(t.Context(), models.LogEntry{LogMessage: "Case escalation failed"})
+		if run.err != nil || run.result.LabelID != "WORKFLOW_ERROR" || run.result.LLMProvider != "local" {
+			t.Fatalf("expected the local provider to answer, got %+v, %v", run.result, run.err)
+		}
+	}
+	if hostedCalls != 3 {
+		t.Fatalf("expected the hosted breaker to open after 3 failures, got %d calls", hostedCalls)
+	}
+	if req := requests[0]; req.Model != "llama3" || !strings.HasPrefix(req.Messages[0].Content, "Pick one of: ") {
+		t.Fatalf("expected the local provider's own model and prompt, got %+v", req)
+	}
+	if reasons := DegradedReasons(); !slices.Contains(reasons, "llm/hosted circuit open") {
+		t.Fatalf("expected the hosted breaker in the health reasons, got %v", reasons)
+	}
+	if slices.Contains(serviceBreakers(), llmBreaker) {
+		t.Fatal("expected the unused llm breaker to be left out of the health check")
+	}
+	tr := s.trace(stageRun{}, "accepted", "")
+	if tr.Breaker != "" || tr.Breakers["hosted"] != "open" || tr.Breakers["local"] != "closed" {
+		t.Fatalf("expected per-provider breaker states, got %q %v", tr.Breaker, tr.Breakers)
+	}
+}
+
+func TestLLMStage_AllProvidersFailing(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	cfg := config.Default().LLM
+	cfg.Model = "m"
+	cfg.Providers = []config.LLMProvider{{Name: "a", BaseURL: down.URL}, {Name: "b", BaseURL: down.URL}}
+	if err := ConfigureLLM(cfg); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureLLM(config.Default().LLM)
+
+	s, _ := stageByName("llm")
+	run := s.run(t.Context(), models.LogEntry{LogMessage: "x"})
+	var se *llm.StatusError
+	if !errors.As(run.err, &se) || !strings.Contains(run.err.Error(), "a: chat completions returned status 502") || !strings.Contains(run.err.Error(), "; b: ") {
+		t.Fatalf("expected both providers' errors, got %v", run.err)
+	}
+}
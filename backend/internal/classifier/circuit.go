@@ -15,6 +15,18 @@ const (
 	StateHalfOpen
 )
 
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
 type CircuitBreaker struct {
 	name           string
 	maxFailures    int
@@ -75,7 +87,14 @@ func CallWithBreaker[T any](cb *CircuitBreaker, fn func() (T, error)) (T, error)
 	result, err := fn()
 
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
+	prev := cb.state
+	defer func() {
+		changed := cb.state != prev
+		cb.mu.Unlock()
+		if changed {
+			refreshDegraded()
+		}
+	}()
 
 	// Record result
 	if err != nil {
@@ -84,7 +103,6 @@ func CallWithBreaker[T any](cb *CircuitBreaker, fn func() (T, error)) (T, error)
 		if cb.state == StateHalfOpen || cb.failures >= cb.maxFailures {
 			cb.state = StateOpen
 			metrics.CircuitBreakerState.WithLabelValues(cb.name).Set(1)
-
 		}
 
 		return zero, err
@@ -102,6 +120,8 @@ func CallWithBreaker[T any](cb *CircuitBreaker, fn func() (T, error)) (T, error)
 
 //state
 
+func (cb *CircuitBreaker) Name() string { return cb.name }
+
 func (cb *CircuitBreaker) State() State {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
@@ -113,3 +133,20 @@ var (
 	llmBreaker  = NewCircuitBreaker("llm", 3, 5*time.Second)
 	bertBreaker = NewCircuitBreaker("bert", 5, 10*time.Second) // more tolerant
 )
+
+// serviceBreakers are the live breakers reported in /health. A chat
+// completions provider chain replaces llmBreaker, except that a single
+// provider keeps using it.
+func serviceBreakers() []*CircuitBreaker {
+	breakers := []*CircuitBreaker{bertBreaker}
+	if len(chatLLM) == 0 {
+		breakers = append(breakers, llmBreaker)
+	}
+	for _, c := range chatLLM {
+		breakers = append(breakers, c.breaker)
+	}
+	for _, p := range plugins {
+		breakers = append(breakers, p.cb)
+	}
+	return breakers
+}
@@ -2,6 +2,10 @@ package classifier
 
 import (
 	"errors"
+	"log-classifier/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"testing"
 	"time"
 )
@@ -153,3 +157,28 @@ func TestBreaker_RejectsConcurrentHalfOpenRequests(t *testing.T) {
 		t.Fatalf("expected ErrCircuitOpen, got %v", err)
 	}
 }
+
+func TestBERTStage_FailuresOpenTheBreaker(t *testing.T) {
+	calls := 0
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	ConfigureBERTCanary(down.URL, "", 0)
+	defer ConfigureBERTCanary(defaultBERTServiceURL, "", 0)
+	prev := bertBreaker
+	bertBreaker = NewCircuitBreaker("bert", 5, time.Minute)
+	defer func() { bertBreaker = prev; refreshDegraded() }()
+
+	s, _ := stageByName("bert")
+	for range 4 {
+		s.run(t.Context(), models.LogEntry{LogMessage: "Connection refused"})
+	}
+	if calls != 5 {
+		t.Fatalf("expected the breaker to open after 5 failures, got %d calls", calls)
+	}
+	if reasons := DegradedReasons(); !slices.Contains(reasons, "bert circuit open") {
+		t.Fatalf("expected bert in the health reasons, got %v", reasons)
+	}
+}
@@ -0,0 +1,46 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"log-classifier/internal/budget"
+	"log-classifier/internal/metrics"
+)
+
+var degradedMode = true
+
+// degradedReason describes why a stage could not produce a result.
+func degradedReason(stage string, err error) string {
+	switch {
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrTooManyRequests):
+		return stage + " circuit open"
+	case errors.Is(err, budget.ErrExhausted):
+		return stage + " token budget exhausted"
+	case errors.Is(err, context.DeadlineExceeded):
+		return stage + " timeout"
+	default:
+		return stage + " unavailable"
+	}
+}
+
+// DegradedReasons reports why the server is currently running degraded,
+// one reason per open circuit breaker. It is empty when healthy.
+func DegradedReasons() []string {
+	var reasons []string
+	for _, cb := range serviceBreakers() {
+		if cb.State() != StateClosed {
+			reasons = append(reasons, cb.Name()+" circuit open")
+		}
+	}
+
+	if len(reasons) > 0 {
+		metrics.DegradedMode.Set(1)
+	} else {
+		metrics.DegradedMode.Set(0)
+	}
+	return reasons
+}
+
+func refreshDegraded() {
+	DegradedReasons()
+}
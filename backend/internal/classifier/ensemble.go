@@ -0,0 +1,211 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"log-classifier/internal/config"
+	"log-classifier/internal/models"
+	"log-classifier/internal/taxonomy"
+	"sync"
+	"sync/atomic"
+)
+
+// ensemble runs several stages at the same time and combines their labels.
+type ensemble struct {
+	sources  map[string]bool
+	steps    []step
+	strategy string
+	weights  map[string]float64
+	escalate *step
+}
+
+var ensembleMode atomic.Pointer[ensemble]
+
+// ConfigureEnsemble enables ensemble classification for the configured
+// sources. An empty source list disables it.
+func ConfigureEnsemble(cfg config.EnsembleConfig) error {
+	if len(cfg.Sources) == 0 {
+		ensembleMode.Store(nil)
+		return nil
+	}
+
+	e := &ensemble{
+		sources:  make(map[string]bool, len(cfg.Sources)),
+		strategy: cfg.Strategy,
+		weights:  cfg.Weights,
+	}
+	for _, src := range cfg.Sources {
+		e.sources[src] = true
+	}
+	for _, name := range cfg.Stages {
+		s, ok := stageByName(name)
+		if !ok {
+			return fmt.Errorf("ensemble: unknown stage %q", name)
+		}
+		e.steps = append(e.steps, s)
+	}
+	if cfg.EscalateTo != "" {
+		s, ok := stageByName(cfg.EscalateTo)
+		if !ok {
+			return fmt.Errorf("ensemble: unknown escalation stage %q", cfg.EscalateTo)
+		}
+		e.escalate = &s
+	}
+
+	ensembleMode.Store(e)
+	return nil
+}
+
+func (e *ensemble) handles(entry models.LogEntry) bool {
+	return e != nil && e.sources[entry.Source]
+}
+
+func (e *ensemble) Classify(entry models.LogEntry, opts Options) *models.ClassificationResult {
+	// as in the primary pipeline, the entry gets entryBudget, shared by the
+	// secret check, the votes and the escalation
+	ctx, cancel := context.WithTimeout(context.Background(), entryBudget)
+	defer cancel()
+
+	// like the primary pipeline, a leaked secret decides the result before
+	// any stage gets to vote
+	if result, trace := checkSecrets(ctx, entry); result != nil {
+		return withTrace(result, trace, opts)
+	}
+
+	votes := make([]models.StageVote, len(e.steps))
+	trace := make([]models.StageTrace, len(e.steps))
+
+	var wg sync.WaitGroup
+	for i, s := range e.steps {
+		wg.Add(1)
+		go func(i int, s step) {
+			defer wg.Done()
+			votes[i] = models.StageVote{Stage: s.stage.Name()}
+
+			run := s.run(ctx, entry)
+			switch {
+			case run.err != nil:
+				votes[i].Error = run.err.Error()
+				trace[i] = s.trace(run, "error", "")
+			case run.result == nil:
+				votes[i].Error = "no result"
+				trace[i] = s.trace(run, "no_result", "")
+			default:
+				votes[i].LabelID = run.result.LabelID
+				votes[i].Label = run.result.Label
+				votes[i].Confidence = run.result.Confidence
+				trace[i] = s.trace(run, "vote", "ensemble strategy "+e.strategy)
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	result := e.combine(votes)
+	if result == nil {
+		result, trace = e.escalateEntry(ctx, entry, trace)
+	}
+	result.Votes = votes
+	result.LogSource = entry.Source
+	return withTrace(result, trace, opts)
+}
+
+func (e *ensemble) escalateEntry(ctx context.Context, entry models.LogEntry, trace []models.StageTrace) (*models.ClassificationResult, []models.StageTrace) {
+	if e.escalate != nil {
+		run := e.escalate.run(ctx, entry)
+		if run.err == nil && run.result != nil {
+			if ok, rule := e.escalate.accept(run.result); ok {
+				run.result.Escalated = true
+				return run.result, append(trace, e.escalate.trace(run, "accepted", "escalated: "+rule))
+			}
+		}
+		trace = append(trace, e.escalate.trace(run, "rejected", "escalated"))
+	}
+	result := unclassified(entry)
+	result.Escalated = true
+	return result, trace
+}
+
+// combine merges the votes according to the strategy. It returns nil
+// when the votes have to be escalated.
+func (e *ensemble) combine(votes []models.StageVote) *models.ClassificationResult {
+	var valid []models.StageVote
+	for _, v := range votes {
+		if v.Error == "" && v.LabelID != "" && v.LabelID != taxonomy.Unclassified {
+			valid = append(valid, v)
+		}
+	}
+	if len(valid) == 0 {
+		return nil
+	}
+
+	switch e.strategy {
+	case "agree":
+		if len(valid) != len(votes) {
+			return nil
+		}
+		conf := valid[0].Confidence
+		for _, v := range valid[1:] {
+			if v.LabelID != valid[0].LabelID {
+				return nil
+			}
+			conf = min(conf, v.Confidence)
+		}
+		return ensembleResult(valid[0], conf)
+
+	case "weighted":
+		scores := map[string]float64{}
+		var total float64
+		for _, v := range valid {
+			w := e.weight(v.Stage)
+			scores[v.LabelID] += w * v.Confidence
+			total += w
+		}
+		best := pickBest(valid, scores)
+		if total == 0 {
+			return ensembleResult(best, 0)
+		}
+		return ensembleResult(best, scores[best.LabelID]/total)
+
+	default: // majority
+		counts := map[string]float64{}
+		confs := map[string]float64{}
+		for _, v := range valid {
+			counts[v.LabelID]++
+			confs[v.LabelID] += v.Confidence
+		}
+		// ties are broken by the summed confidence
+		scores := map[string]float64{}
+		for label, n := range counts {
+			scores[label] = n + confs[label]/float64(len(valid)+1)
+		}
+		best := pickBest(valid, scores)
+		return ensembleResult(best, confs[best.LabelID]/counts[best.LabelID])
+	}
+}
+
+func (e *ensemble) weight(stage string) float64 {
+	if w, ok := e.weights[stage]; ok {
+		return w
+	}
+	return 1.0
+}
+
+// pickBest returns the first vote carrying the highest scoring label.
+func pickBest(votes []models.StageVote, scores map[string]float64) models.StageVote {
+	best := votes[0]
+	for _, v := range votes[1:] {
+		if scores[v.LabelID] > scores[best.LabelID] {
+			best = v
+		}
+	}
+	return best
+}
+
+func ensembleResult(v models.StageVote, confidence float64) *models.ClassificationResult {
+	return &models.ClassificationResult{
+		LabelID:    v.LabelID,
+		Label:      v.Label,
+		Classifier: "ensemble",
+		Confidence: confidence,
+	}
+}
@@ -0,0 +1,87 @@
+package classifier
+
+import (
+	"log-classifier/internal/models"
+	"testing"
+	"time"
+)
+
+func TestEnsemble_Majority(t *testing.T) {
+	e := &ensemble{strategy: "majority"}
+	votes := []models.StageVote{
+		{Stage: "regex", LabelID: "DB_ERROR", Confidence: 0.6},
+		{Stage: "bert", LabelID: "AUTH_ERROR", Confidence: 0.9},
+		{Stage: "llm", LabelID: "DB_ERROR", Confidence: 0.8},
+	}
+
+	r := e.combine(votes)
+	if r == nil || r.LabelID != "DB_ERROR" {
+		t.Fatalf("expected DB_ERROR, got %+v", r)
+	}
+	if r.Confidence != 0.7 {
+		t.Fatalf("expected mean confidence 0.7, got %v", r.Confidence)
+	}
+}
+
+func TestEnsemble_WeightedConfidence(t *testing.T) {
+	e := &ensemble{strategy: "weighted", weights: map[string]float64{"llm": 3}}
+	votes := []models.StageVote{
+		{Stage: "bert", LabelID: "DB_ERROR", Confidence: 0.9},
+		{Stage: "llm", LabelID: "WORKFLOW_ERROR", Confidence: 0.5},
+	}
+
+	r := e.combine(votes)
+	if r == nil || r.LabelID != "WORKFLOW_ERROR" {
+		t.Fatalf("expected WORKFLOW_ERROR, got %+v", r)
+	}
+}
+
+func TestEnsemble_AgreeEscalatesOnSplitVote(t *testing.T) {
+	e := &ensemble{strategy: "agree"}
+
+	agree := []models.StageVote{
+		{Stage: "bert", LabelID: "DB_ERROR", Confidence: 0.9},
+		{Stage: "llm", LabelID: "DB_ERROR", Confidence: 0.7},
+	}
+	if r := e.combine(agree); r == nil || r.Confidence != 0.7 {
+		t.Fatalf("expected agreement with confidence 0.7, got %+v", r)
+	}
+
+	split := []models.StageVote{
+		{Stage: "bert", LabelID: "DB_ERROR", Confidence: 0.9},
+		{Stage: "llm", LabelID: "AUTH_ERROR", Confidence: 0.7},
+	}
+	if r := e.combine(split); r != nil {
+		t.Fatalf("expected escalation, got %+v", r)
+	}
+
+	failed := []models.StageVote{
+		{Stage: "bert", LabelID: "DB_ERROR", Confidence: 0.9},
+		{Stage: "llm", Error: "timeout"},
+	}
+	if r := e.combine(failed); r != nil {
+		t.Fatalf("expected escalation when a stage fails, got %+v", r)
+	}
+}
+
+func TestEnsemble_SharesEntryBudget(t *testing.T) {
+	defer func(b time.Duration) { entryBudget = b }(entryBudget)
+	entryBudget = 50 * time.Millisecond
+
+	hung := &fakeStage{name: "bert", result: &models.ClassificationResult{LabelID: "DB_ERROR", Confidence: 0.9}, delay: time.Minute}
+	escalate := &fakeStage{name: "llm", result: &models.ClassificationResult{LabelID: "DB_ERROR", Confidence: 0.9}, delay: time.Minute}
+	e := &ensemble{
+		strategy: "agree",
+		steps:    []step{{stage: hung, attempts: 1, accept: anyResult}},
+		escalate: &step{stage: escalate, attempts: 1, accept: anyResult},
+	}
+
+	start := time.Now()
+	r := e.Classify(models.LogEntry{LogMessage: "x"}, Options{})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the entry budget to cut the votes and the escalation short, took %v", elapsed)
+	}
+	if r.LabelID != "UNCLASSIFIED" || !r.Escalated || r.Votes[0].Error == "" {
+		t.Fatalf("expected an escalated UNCLASSIFIED result, got %+v", r)
+	}
+}
@@ -0,0 +1,59 @@
+package classifier
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const latencyWindow = 128
+
+// latencyTracker keeps the most recent call durations per stage so the
+// pipeline can estimate how long a stage is likely to take.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples map[string]*latencyRing
+}
+
+type latencyRing struct {
+	values []time.Duration
+	next   int
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{samples: make(map[string]*latencyRing)}
+}
+
+func (t *latencyTracker) observe(stage string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.samples[stage]
+	if !ok {
+		r = &latencyRing{values: make([]time.Duration, 0, latencyWindow)}
+		t.samples[stage] = r
+	}
+	if len(r.values) < latencyWindow {
+		r.values = append(r.values, d)
+		return
+	}
+	r.values[r.next] = d
+	r.next = (r.next + 1) % latencyWindow
+}
+
+// p50 returns the median of the recent samples, or 0 if there are none.
+func (t *latencyTracker) p50(stage string) time.Duration {
+	t.mu.Lock()
+	r, ok := t.samples[stage]
+	if !ok || len(r.values) == 0 {
+		t.mu.Unlock()
+		return 0
+	}
+	values := slices.Clone(r.values)
+	t.mu.Unlock()
+
+	slices.Sort(values)
+	return values[len(values)/2]
+}
+
+var stageLatency = newLatencyTracker()
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log-classifier/internal/llm"
 	"log-classifier/internal/models"
+	"log-classifier/internal/taxonomy"
 	"net/http"
 	"time"
 )
@@ -17,9 +19,9 @@ var llmClient = &http.Client{
 
 const llmServiceURL = "http://127.0.0.1:5001/classify"
 
-func callLLMInternal(msg string) (*models.ClassificationResult, error) {
+func callLLMInternal(ctx context.Context, msg string) (*models.ClassificationResult, error) {
 	fmt.Println("DEBUG: BERT CALLED with:", msg)
-	return CallWithBreaker(llmBreaker, func() (*models.ClassificationResult, error) {
+	return CallWithBreaker(breakerFor(ctx, llmBreaker), func() (*models.ClassificationResult, error) {
 
 		reqBody := map[string]string{
 			"message": msg,
@@ -41,22 +43,32 @@ func callLLMInternal(msg string) (*models.ClassificationResult, error) {
 			return nil, fmt.Errorf("LLM service returned status %d: %s", resp.StatusCode, string(body))
 		}
 
-		var result models.ClassificationResult
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("failed to decode LLM response: %w", err)
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LLM response: %w", err)
 		}
-
-		return &result, nil
+		return serviceAnswer(ctx, string(body))
 	})
 }
 
+// serviceAnswer validates the LLM service's reply like a chat answer,
+// except that the extra result fields it sends are ignored.
+func serviceAnswer(ctx context.Context, body string) (*models.ClassificationResult, error) {
+	answer, err := llm.NewLenientParser(taxonomy.Current().Labels()).Parse(body)
+	if err != nil {
+		countAnswer(ctx, "invalid")
+		return nil, err
+	}
+	return answerResult(ctx, answer), nil
+}
+
 // Public API with timeout
 func CallLLMWithTimeout(ctx context.Context, msg string) (*models.ClassificationResult, error) {
 	resultCh := make(chan *models.ClassificationResult, 1)
 	errCh := make(chan error, 1)
 
 	go func() {
-		result, err := callLLMInternal(msg)
+		result, err := callLLMInternal(ctx, msg)
 		if err != nil {
 			errCh <- err
 			return
@@ -0,0 +1,41 @@
+package classifier
+
+import (
+	"log-classifier/internal/models"
+	"log-classifier/internal/taxonomy"
+	"sort"
+)
+
+// expandLabels fills in the multi-label shape of a result. Stages that
+// only produce a single label get a one-element list, and every label
+// carries its path in the taxonomy. A label listed more than once keeps
+// its highest confidence.
+func expandLabels(r *models.ClassificationResult) {
+	reg := taxonomy.Current()
+
+	labels := make([]models.LabelScore, 0, len(r.Labels)+1)
+	index := make(map[string]int, len(r.Labels)+1)
+
+	labels = append(labels, models.LabelScore{LabelID: r.LabelID, Label: r.Label, Confidence: r.Confidence})
+	index[r.LabelID] = 0
+	for _, l := range r.Labels {
+		i, ok := index[l.LabelID]
+		if !ok {
+			index[l.LabelID] = len(labels)
+			labels = append(labels, l)
+			continue
+		}
+		if l.Confidence > labels[i].Confidence {
+			labels[i].Confidence = l.Confidence
+		}
+	}
+
+	// keep the primary label first, order the rest by confidence
+	rest := labels[1:]
+	sort.SliceStable(rest, func(i, j int) bool { return rest[i].Confidence > rest[j].Confidence })
+
+	for i := range labels {
+		labels[i].Path = reg.Path(labels[i].LabelID)
+	}
+	r.Labels = labels
+}
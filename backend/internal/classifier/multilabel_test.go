@@ -0,0 +1,55 @@
+package classifier
+
+import (
+	"fmt"
+	"log-classifier/internal/models"
+	"testing"
+)
+
+func TestExpandLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		result models.ClassificationResult
+		want   string
+	}{
+		{
+			name:   "single label",
+			result: models.ClassificationResult{LabelID: "DB_ERROR", Label: "Database Error", Confidence: 0.9},
+			want:   "[DB_ERROR 0.9 [ERROR DB_ERROR]]",
+		},
+		{
+			name: "secondary labels by confidence",
+			result: models.ClassificationResult{LabelID: "AUTH_ERROR", Confidence: 0.6, Labels: []models.LabelScore{
+				{LabelID: "BACKUP", Confidence: 0.1},
+				{LabelID: "USER_ACTION", Confidence: 0.3},
+			}},
+			want: "[AUTH_ERROR 0.6 [ERROR AUTH_ERROR]] [USER_ACTION 0.3 [USER_ACTION]] [BACKUP 0.1 [BACKUP]]",
+		},
+		{
+			name: "primary label repeated",
+			result: models.ClassificationResult{LabelID: "INFO", Confidence: 0.5, Labels: []models.LabelScore{
+				{LabelID: "INFO", Confidence: 0.8},
+				{LabelID: "BACKUP", Confidence: 0.2},
+				{LabelID: "BACKUP", Confidence: 0.4},
+			}},
+			// the highest confidence of a repeated label survives
+			want: "[INFO 0.8 [INFO]] [BACKUP 0.4 [BACKUP]]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.result
+			expandLabels(&r)
+			var got string
+			for i, l := range r.Labels {
+				if i > 0 {
+					got += " "
+				}
+				got += fmt.Sprint([]any{l.LabelID, l.Confidence, l.Path})
+			}
+			if got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
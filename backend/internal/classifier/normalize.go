@@ -0,0 +1,91 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"log-classifier/internal/config"
+	"log-classifier/internal/models"
+	"log-classifier/internal/normalize"
+	"log-classifier/internal/taxonomy"
+)
+
+// Stage inputs.
+const (
+	inputRaw        = "raw"
+	inputNormalized = "normalized"
+)
+
+var (
+	// normalizer is nil unless normalization is enabled.
+	normalizer *normalize.Normalizer
+	// stageInputs maps a stage name to inputRaw or inputNormalized.
+	// Stages not listed get normalized input, except regex and secret.
+	stageInputs = rawInputs()
+)
+
+// ConfigureNormalization enables message normalization and chooses each
+// stage's input. It must be called before the server starts handling
+// requests, after ConfigurePlugins so that plugin stages can have inputs.
+func ConfigureNormalization(cfg config.NormalizeConfig) error {
+	if !cfg.Enabled {
+		normalizer = nil
+		return nil
+	}
+
+	ruleSet := normalize.Default()
+	if len(cfg.Rules) > 0 {
+		ruleSet = ruleSet[:0]
+		for _, r := range cfg.Rules {
+			ruleSet = append(ruleSet, normalize.Rule{Name: r.Name, Pattern: r.Pattern, Placeholder: r.Placeholder})
+		}
+	}
+	n, err := normalize.New(ruleSet)
+	if err != nil {
+		return err
+	}
+
+	inputs := rawInputs()
+	for stage, input := range cfg.Inputs {
+		if !knownStage(stage) {
+			// a misspelled stage would silently keep its default input
+			return fmt.Errorf("normalize.inputs: unknown stage %q", stage)
+		}
+		if input != inputRaw && input != inputNormalized {
+			return fmt.Errorf("normalize.inputs.%s must be raw or normalized, got %q", stage, input)
+		}
+		inputs[stage] = input
+	}
+
+	normalizer = n
+	stageInputs = inputs
+	return nil
+}
+
+// rawInputs are the stages that see the raw message by default: regex
+// rules and entities are written against it, and secret locations refer
+// to it.
+func rawInputs() map[string]string {
+	return map[string]string{"regex": inputRaw, "secret": inputRaw}
+}
+
+// stageEntry returns the entry as the named stage should see it:
+// redacted first, so that placeholders are typed, then normalized.
+func stageEntry(ctx context.Context, stage string, entry models.LogEntry) models.LogEntry {
+	entry = redactEntry(ctx, stage, entry)
+	if normalizer == nil || stageInputs[stage] == inputRaw {
+		return entry
+	}
+	entry.LogMessage = normalizer.Normalize(entry.LogMessage)
+	return entry
+}
+
+// withMessages keeps the original message on the result, next to the
+// normalized one, when normalization is enabled. Messages that leak a
+// secret are not echoed.
+func withMessages(entry models.LogEntry, result *models.ClassificationResult) {
+	if normalizer == nil || result.LabelID == taxonomy.SecretLeak {
+		return
+	}
+	result.OriginalMessage = entry.LogMessage
+	result.NormalizedMessage = normalizer.Normalize(entry.LogMessage)
+}
@@ -0,0 +1,66 @@
+package classifier
+
+import (
+	"log-classifier/internal/config"
+	"log-classifier/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestNormalization_PerStageInput(t *testing.T) {
+	err := ConfigureNormalization(config.NormalizeConfig{Enabled: true, Inputs: map[string]string{"llm": "raw"}})
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureNormalization(config.NormalizeConfig{})
+
+	raw := &recordingStage{fakeStage: fakeStage{name: "llm"}}
+	masked := &recordingStage{fakeStage: fakeStage{name: "bert", result: &models.ClassificationResult{LabelID: "DB_ERROR", Confidence: 0.9}}}
+	p := &Pipeline{steps: []step{
+		{stage: raw, attempts: 1, accept: anyResult},
+		{stage: masked, attempts: 1, accept: anyResult},
+	}}
+
+	msg := "Connection to 10.0.0.12:5432 failed after 3 retries"
+	p.Classify(models.LogEntry{LogMessage: msg}, Options{})
+
+	if len(raw.seen) != 1 || raw.seen[0] != msg {
+		t.Fatalf("raw stage saw %q", raw.seen)
+	}
+	if want := "Connection to <IP> failed after <NUM> retries"; len(masked.seen) != 1 || masked.seen[0] != want {
+		t.Fatalf("expected masked stage to see %q, got %q", want, masked.seen)
+	}
+}
+
+func TestNormalization_KeepsOriginalMessage(t *testing.T) {
+	if err := ConfigureNormalization(config.NormalizeConfig{Enabled: true}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureNormalization(config.NormalizeConfig{})
+
+	result := &models.ClassificationResult{}
+	withMessages(models.LogEntry{LogMessage: "took 250ms"}, result)
+	if result.OriginalMessage != "took 250ms" || result.NormalizedMessage != "took <DURATION>" {
+		t.Fatalf("unexpected messages: %q, %q", result.OriginalMessage, result.NormalizedMessage)
+	}
+}
+
+func TestConfigureNormalization_RejectsBadInput(t *testing.T) {
+	err := ConfigureNormalization(config.NormalizeConfig{Enabled: true, Inputs: map[string]string{"bert": "masked"}})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if normalizer != nil {
+		t.Fatalf("a rejected config must not enable normalization")
+	}
+}
+
+func TestConfigureNormalization_RejectsUnknownStage(t *testing.T) {
+	err := ConfigureNormalization(config.NormalizeConfig{Enabled: true, Inputs: map[string]string{"bret": "raw"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown stage "bret"`) {
+		t.Fatalf("expected an unknown stage error, got %v", err)
+	}
+	if normalizer != nil {
+		t.Fatalf("a rejected config must not enable normalization")
+	}
+}
@@ -2,54 +2,495 @@ package classifier
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log-classifier/internal/budget"
+	"log-classifier/internal/config"
+	"log-classifier/internal/metrics"
 	"log-classifier/internal/models"
-	"strings"
+	"log-classifier/internal/taxonomy"
+	"slices"
 	"time"
 )
 
-func Classify(entry models.LogEntry) *models.ClassificationResult {
+// Stage is a single classifier that the pipeline can consult.
+// A nil result with a nil error means the stage has no opinion.
+type Stage interface {
+	Name() string
+	Classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error)
+}
 
-	// fmt.Printf("DEBUG LogMessage = %#v\n", entry.LogMessage)
-	//regex
-	if result := ClassifyWithRegex(entry.LogMessage); result != nil {
-		result.LogSource = entry.Source
-		return result
+// step wraps a stage with the pipeline policy used to call it.
+type step struct {
+	stage    Stage
+	timeout  time.Duration // 0 means no per-stage timeout
+	attempts int
+	accept   acceptFunc
+}
+
+// acceptFunc decides whether the pipeline stops at a stage's result and
+// describes the rule or threshold that decided it.
+type acceptFunc func(*models.ClassificationResult) (bool, string)
+
+// stageRun is the outcome of running one step.
+type stageRun struct {
+	result   *models.ClassificationResult
+	actions  *ruleActions
+	template int // the template the entry was filed under, or 0
+	err      error
+	attempts int
+	latency  time.Duration
+}
+
+func (s step) run(ctx context.Context, entry models.LogEntry) stageRun {
+	entry = stageEntry(ctx, s.stage.Name(), entry)
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
 	}
 
-	// bert
-	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
-	defer cancel()
+	attempts := s.attempts
+	if attempts < 1 {
+		attempts = 1
+	}
 
-	bertResult, err := Retry(ctx, 2, func() (*models.ClassificationResult, error) {
-		result, err := ClassifyWithBERT(ctx, entry.LogMessage)
-		if err != nil && strings.Contains(err.Error(), "circuit open") {
+	var run stageRun
+	start := time.Now()
+	as, hasActions := s.stage.(actionStage)
+	ts, isTemplate := s.stage.(templateStage)
+	run.result, run.err = Retry(ctx, attempts, func() (*models.ClassificationResult, error) {
+		run.attempts++
+		var result *models.ClassificationResult
+		var err error
+		switch {
+		case hasActions:
+			result, run.actions, err = as.classifyWithActions(ctx, entry)
+		case isTemplate:
+			result, run.template, err = ts.file(ctx, entry)
+		default:
+			result, err = s.stage.Classify(ctx, entry)
+		}
+		if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrTooManyRequests) || errors.Is(err, budget.ErrExhausted) {
 			return nil, Permanent(err) // stops retry immediately
 		}
 		return result, err
 	})
+	run.latency = time.Since(start)
 
-	if err == nil && bertResult != nil {
-		if bertResult.LabelID != "UNCLASSIFIED" && bertResult.Confidence >= 0.2 {
-			return bertResult
+	if run.err == nil && run.result != nil {
+		run.err = validateLabel(metricName(ctx, s.stage.Name()), run.result)
+		if run.err != nil {
+			run.result = nil
+		} else {
+			calibrate(s.stage.Name(), run.result)
 		}
 	}
 
-	//llm with timeout + retry
-	llmCtx, cancelLLM := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancelLLM()
+	metrics.ClassificationDuration.WithLabelValues(metricName(ctx, s.stage.Name())).Observe(run.latency.Seconds())
+	if run.err == nil {
+		stageLatency.observe(metricName(ctx, s.stage.Name()), run.latency)
+	}
+	return run
+}
 
-	llmResult, err := Retry(llmCtx, 2, func() (*models.ClassificationResult, error) {
-		return CallLLMWithTimeout(llmCtx, entry.LogMessage)
-	})
+// trace describes the run for explain mode. outcome and rule are filled
+// in by the caller, which knows how the result was used.
+func (s step) trace(run stageRun, outcome, rule string) models.StageTrace {
+	t := models.StageTrace{
+		Stage:     s.stage.Name(),
+		Outcome:   outcome,
+		Rule:      rule,
+		LatencyMs: float64(run.latency.Microseconds()) / 1000,
+	}
+	if run.attempts > 1 {
+		t.Retries = run.attempts - 1
+	}
+	if run.err != nil {
+		t.Error = run.err.Error()
+	}
+	if run.result != nil {
+		t.LabelID = run.result.LabelID
+		t.Confidence = run.result.Confidence
+		if t.Rule == "" {
+			t.Rule = run.result.MatchedRule
+		}
+	}
+	if b, ok := s.stage.(interface{ breaker() *CircuitBreaker }); ok {
+		if cb := b.breaker(); cb != nil {
+			t.Breaker = cb.State().String()
+		}
+	}
+	if p, ok := s.stage.(interface{ providerBreakers() map[string]string }); ok {
+		t.Breakers = p.providerBreakers()
+	}
+	return t
+}
+
+// Pipeline runs its steps in order and short-circuits on the first
+// result a step accepts. Every entry gets entryBudget to finish; a step
+// is skipped when the remaining budget is below its median latency, and
+// the best result seen so far is returned instead.
+type Pipeline struct {
+	name  string
+	steps []step
+
+	// shadow pipelines only observe: see withShadow
+	shadow bool
+}
+
+var entryBudget = 6 * time.Second
+
+// ConfigurePipeline sets the per-entry time budget and degraded mode.
+// It must be called before the server starts handling requests.
+func ConfigurePipeline(cfg config.PipelineConfig) {
+	entryBudget = cfg.Budget
+	degradedMode = cfg.DegradedMode
+}
+
+// pipelineRun is the state of one entry moving through a pipeline and
+// any sub-pipeline it is routed to.
+type pipelineRun struct {
+	entry   models.LogEntry
+	best    *models.ClassificationResult
+	failure string
+	trace   []models.StageTrace
+	tags    []string
+
+	// template is set once the template stage has filed the entry
+	template int
 
-	if err == nil && llmResult != nil {
-		return llmResult
+	// skipped are the stages that refused to run, e.g. for lack of budget
+	skipped []models.SkippedStage
+}
+
+func (p *Pipeline) Classify(entry models.LogEntry, opts Options) *models.ClassificationResult {
+	ctx := context.Background()
+	if p.shadow {
+		ctx = withShadow(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, entryBudget)
+	defer cancel()
+
+	pr := &pipelineRun{entry: entry}
+	result := p.classify(ctx, pr)
+	if len(pr.tags) > 0 {
+		result.Tags = append(result.Tags, pr.tags...)
+	}
+	result.Skipped = append(pr.skipped, result.Skipped...)
+	if pr.template != 0 && !p.shadow {
+		learnTemplate(pr.template, result)
 	}
+	return withTrace(result, pr.trace, opts)
+}
+
+func (p *Pipeline) classify(ctx context.Context, pr *pipelineRun) *models.ClassificationResult {
+	deadline, _ := ctx.Deadline()
+
+	for i, s := range p.steps {
+		remaining := time.Until(deadline)
+		if expected := stageLatency.p50(metricName(ctx, s.stage.Name())); remaining <= 0 || remaining < expected {
+			reason := fmt.Sprintf("budget: %s remaining, p50 latency %s", remaining.Round(time.Millisecond), expected.Round(time.Millisecond))
+			return p.budgetExhausted(ctx, pr.entry, pr.best, i, reason)
+		}
+
+		run := s.run(ctx, pr.entry)
+		if run.template != 0 {
+			pr.template = run.template
+		}
+		if errors.Is(run.err, budget.ErrExhausted) {
+			metrics.StagesSkipped.WithLabelValues(metricName(ctx, s.stage.Name()), "token_budget").Inc()
+			pr.skipped = append(pr.skipped, models.SkippedStage{Stage: s.stage.Name(), Reason: run.err.Error()})
+			pr.failure = degradedReason(s.stage.Name(), run.err)
+			continue
+		}
+		if run.err != nil {
+			pr.failure = degradedReason(s.stage.Name(), run.err)
+			pr.trace = append(pr.trace, s.trace(run, "error", ""))
+			continue
+		}
+
+		if a := run.actions; a != nil {
+			pr.tags = append(pr.tags, a.tags...)
+			if a.message != "" {
+				pr.entry.LogMessage = a.message
+			}
+			if a.drop {
+				pr.trace = append(pr.trace, s.trace(run, "dropped", "rule "+run.result.MatchedRule))
+				run.result.LogSource = pr.entry.Source
+				return run.result
+			}
+			if a.route != "" {
+				if sub, ok := routes[a.route]; ok {
+					pr.trace = append(pr.trace, s.trace(run, "routed", "route "+a.route))
+					return sub.classify(ctx, pr)
+				}
+				metrics.RuleActions.WithLabelValues("route_unknown").Inc()
+			}
+		}
 
+		if run.result == nil {
+			pr.trace = append(pr.trace, s.trace(run, "no_result", ""))
+			continue
+		}
+
+		ok, rule := s.accept(run.result)
+		if ok {
+			pr.trace = append(pr.trace, s.trace(run, "accepted", rule))
+			run.result.LogSource = pr.entry.Source
+			return run.result
+		}
+		pr.trace = append(pr.trace, s.trace(run, "rejected", rule))
+		if run.result.LabelID != taxonomy.Unclassified && (pr.best == nil || run.result.Confidence > pr.best.Confidence) {
+			pr.best = run.result
+		}
+	}
+
+	if degradedMode && pr.best != nil && pr.failure != "" {
+		return degrade(ctx, pr.entry, pr.best, pr.failure)
+	}
+	return unclassified(pr.entry)
+}
+
+// withTrace attaches the stage trace when explain mode was requested.
+// Skipped stages recorded on the result are appended to the trace.
+func withTrace(result *models.ClassificationResult, trace []models.StageTrace, opts Options) *models.ClassificationResult {
+	if !opts.Explain {
+		return result
+	}
+	for _, sk := range result.Skipped {
+		trace = append(trace, models.StageTrace{Stage: sk.Stage, Outcome: "skipped", Rule: sk.Reason})
+	}
+	result.Trace = trace
+	return result
+}
+
+// degrade flags a best-effort result that was returned because a later
+// stage was unavailable. Only live results are counted.
+func degrade(ctx context.Context, entry models.LogEntry, best *models.ClassificationResult, reason string) *models.ClassificationResult {
+	best.LogSource = entry.Source
+	best.Degraded = true
+	best.DegradedReason = reason
+	if !isShadow(ctx) {
+		metrics.DegradedResults.WithLabelValues(reason).Inc()
+	}
+	return best
+}
+
+// budgetExhausted records the steps from index skipped onwards as skipped
+// and returns the best result seen so far.
+func (p *Pipeline) budgetExhausted(ctx context.Context, entry models.LogEntry, best *models.ClassificationResult, skipped int, reason string) *models.ClassificationResult {
+	var result *models.ClassificationResult
+	switch {
+	case best == nil:
+		result = unclassified(entry)
+	case degradedMode:
+		result = degrade(ctx, entry, best, "time budget exhausted")
+	default:
+		result = best
+		result.LogSource = entry.Source
+	}
+
+	for _, s := range p.steps[skipped:] {
+		metrics.StagesSkipped.WithLabelValues(metricName(ctx, s.stage.Name()), "budget").Inc()
+		result.Skipped = append(result.Skipped, models.SkippedStage{Stage: s.stage.Name(), Reason: reason})
+	}
+	return result
+}
+
+// validateLabel maps the label reported by a stage onto the taxonomy,
+// replacing the display name with the canonical one.
+func validateLabel(stage string, r *models.ClassificationResult) error {
+	label, remapped, err := taxonomy.Current().Resolve(r.LabelID)
+	if err != nil {
+		metrics.LabelValidations.WithLabelValues(stage, "rejected").Inc()
+		return fmt.Errorf("%s: %w", stage, err)
+	}
+
+	if remapped {
+		metrics.LabelValidations.WithLabelValues(stage, "remapped").Inc()
+	} else {
+		metrics.LabelValidations.WithLabelValues(stage, "valid").Inc()
+	}
+	r.LabelID = label.ID
+	r.Label = label.Name
+
+	// secondary labels are optional, so unknown ones are dropped rather
+	// than failing the whole result
+	valid := r.Labels[:0]
+	for _, ls := range r.Labels {
+		l, _, err := taxonomy.Current().Resolve(ls.LabelID)
+		if err != nil {
+			metrics.LabelValidations.WithLabelValues(stage, "rejected").Inc()
+			continue
+		}
+		ls.LabelID = l.ID
+		ls.Label = l.Name
+		valid = append(valid, ls)
+	}
+	r.Labels = valid
+	return nil
+}
+
+func unclassified(entry models.LogEntry) *models.ClassificationResult {
 	return &models.ClassificationResult{
-		LabelID:    "UNCLASSIFIED",
-		Label:      "Unclassified",
+		LabelID:    taxonomy.Unclassified,
+		Label:      taxonomy.Current().Name(taxonomy.Unclassified),
 		Classifier: "orchestrator",
+		LogSource:  entry.Source,
 		Confidence: 0.0,
 	}
 }
+
+var confidentBERT = acceptAbove("bert")
+
+// stageByName returns the step used for a named stage in the default
+// pipeline, so alternate pipelines share the same policy.
+func stageByName(name string) (step, bool) {
+	switch name {
+	case "secret":
+		return step{stage: secretStage{}, attempts: 1, accept: acceptAbove("secret")}, true
+	case "regex":
+		return step{stage: regexStage{}, attempts: 1, accept: acceptAbove("regex")}, true
+	case "template":
+		return step{stage: templateStage{}, attempts: 1, accept: acceptAbove("template")}, true
+	case "bayes":
+		return step{stage: bayesStage{}, attempts: 1, accept: acceptAbove("bayes")}, true
+	case "bert":
+		return step{stage: bertStage{}, timeout: 4 * time.Second, attempts: 2, accept: confidentBERT}, true
+	case "llm":
+		return step{stage: llmStage{}, timeout: llmTimeout, attempts: 2, accept: acceptAbove("llm")}, true
+	}
+	if p, ok := pluginByName(name); ok {
+		return step{stage: p, timeout: p.timeout, attempts: 2, accept: acceptAbove(name)}, true
+	}
+	return step{}, false
+}
+
+// knownStage reports whether name is a stage that can run: a built-in
+// stage, a plugin or the shadow candidate.
+func knownStage(name string) bool {
+	_, ok := stageByName(name)
+	return ok || name == "candidate"
+}
+
+// ClassifyRaw runs a single named stage with its usual timeout and retry
+// policy but without calibration. It is used to fit calibration tables.
+func ClassifyRaw(stage string, entry models.LogEntry) (*models.ClassificationResult, error) {
+	s, ok := stageByName(stage)
+	if !ok {
+		return nil, fmt.Errorf("unknown stage %q", stage)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), entryBudget)
+	defer cancel()
+
+	entry = stageEntry(ctx, stage, entry)
+	attempts := max(s.attempts, 1)
+	if s.timeout > 0 {
+		var c context.CancelFunc
+		ctx, c = context.WithTimeout(ctx, s.timeout)
+		defer c()
+	}
+	result, err := Retry(ctx, attempts, func() (*models.ClassificationResult, error) {
+		return s.stage.Classify(ctx, entry)
+	})
+	if err != nil || result == nil {
+		return nil, err
+	}
+	if err := validateLabel(stage, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func newPipeline(name string, stages []string) (*Pipeline, error) {
+	p := &Pipeline{name: name}
+	for _, stage := range stages {
+		s, ok := stageByName(stage)
+		if !ok {
+			return nil, fmt.Errorf("pipeline %s: unknown stage %q", name, stage)
+		}
+		p.steps = append(p.steps, s)
+	}
+	return p, nil
+}
+
+func newDefaultPipeline() *Pipeline {
+	var stages []string
+	if secretDetector != nil {
+		stages = append(stages, "secret")
+	}
+	stages = append(stages, "regex")
+	if templateMiner != nil {
+		stages = append(stages, "template")
+	}
+	if bayesModel != nil {
+		stages = append(stages, "bayes")
+	}
+	for _, next := range []string{"bert", "llm"} {
+		for _, p := range plugins {
+			if p.before == next {
+				stages = append(stages, p.name)
+			}
+		}
+		stages = append(stages, next)
+	}
+	p, _ := newPipeline("primary", stages)
+	return p
+}
+
+// routes are the named sub-pipelines that route rules can send entries to.
+var routes = map[string]*Pipeline{}
+
+// ConfigureRoutes builds the sub-pipelines for route rules. They cannot
+// contain the regex stage, which would route the entry again. It must be
+// called before the server starts handling requests.
+func ConfigureRoutes(cfg map[string][]string) error {
+	r := make(map[string]*Pipeline, len(cfg))
+	for name, stages := range cfg {
+		if slices.Contains(stages, "regex") {
+			return fmt.Errorf("route %s: sub-pipelines cannot contain the regex stage", name)
+		}
+		p, err := newPipeline(name, stages)
+		if err != nil {
+			return err
+		}
+		r[name] = p
+	}
+	routes = r
+	return nil
+}
+
+var primaryPipeline = newDefaultPipeline()
+
+// Options are per-request classification options.
+type Options struct {
+	// Explain attaches a per-stage decision trace to the result.
+	Explain bool
+
+	// MultiLabel returns every applicable label with its taxonomy path.
+	MultiLabel bool
+}
+
+func Classify(entry models.LogEntry) *models.ClassificationResult {
+	return ClassifyWithOptions(entry, Options{})
+}
+
+func ClassifyWithOptions(entry models.LogEntry, opts Options) *models.ClassificationResult {
+	var result *models.ClassificationResult
+	if e := ensembleMode.Load(); e.handles(entry) {
+		result = e.Classify(entry, opts)
+	} else {
+		result = primaryPipeline.Classify(entry, opts)
+	}
+
+	withMessages(entry, result)
+	if opts.MultiLabel {
+		expandLabels(result)
+	} else {
+		result.Labels = nil
+	}
+	shadowClassify(entry, result)
+	return result
+}
@@ -0,0 +1,133 @@
+package classifier
+
+import (
+	"context"
+	"log-classifier/internal/models"
+	"testing"
+	"time"
+)
+
+type fakeStage struct {
+	name   string
+	result *models.ClassificationResult
+	err    error
+	delay  time.Duration
+	calls  int
+}
+
+func (f *fakeStage) Name() string { return f.name }
+
+func (f *fakeStage) Classify(ctx context.Context, _ models.LogEntry) (*models.ClassificationResult, error) {
+	f.calls++
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.result == nil {
+		return nil, nil
+	}
+	r := *f.result
+	return &r, nil
+}
+
+func anyResult(r *models.ClassificationResult) (bool, string) {
+	return true, "first result wins"
+}
+
+func TestPipeline_ShortCircuitsOnAcceptedResult(t *testing.T) {
+	first := &fakeStage{name: "first", result: &models.ClassificationResult{LabelID: "USER_ACTION", Confidence: 0.95}}
+	second := &fakeStage{name: "second"}
+	p := &Pipeline{steps: []step{
+		{stage: first, attempts: 1, accept: anyResult},
+		{stage: second, attempts: 1, accept: anyResult},
+	}}
+
+	r := p.Classify(models.LogEntry{Source: "app", LogMessage: "x"}, Options{})
+	if r.LabelID != "USER_ACTION" || r.LogSource != "app" {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+	if second.calls != 0 {
+		t.Fatalf("second stage should not run")
+	}
+}
+
+func TestPipeline_SkipsStageWhenBudgetBelowP50(t *testing.T) {
+	defer func(b time.Duration) { entryBudget = b }(entryBudget)
+	entryBudget = 100 * time.Millisecond
+
+	unsure := &fakeStage{name: "test-unsure", result: &models.ClassificationResult{LabelID: "DB_ERROR", Confidence: 0.1}, delay: 30 * time.Millisecond}
+	slow := &fakeStage{name: "test-slow", result: &models.ClassificationResult{LabelID: "WORKFLOW_ERROR", Confidence: 0.9}}
+	for i := 0; i < 10; i++ {
+		stageLatency.observe(slow.name, time.Second)
+	}
+
+	p := &Pipeline{steps: []step{
+		{stage: unsure, attempts: 1, accept: confidentBERT},
+		{stage: slow, attempts: 1, accept: anyResult},
+	}}
+
+	r := p.Classify(models.LogEntry{LogMessage: "x"}, Options{})
+	if slow.calls != 0 {
+		t.Fatalf("slow stage should have been skipped")
+	}
+	if r.LabelID != "DB_ERROR" {
+		t.Fatalf("expected best-so-far DB_ERROR, got %s", r.LabelID)
+	}
+	if len(r.Skipped) != 1 || r.Skipped[0].Stage != "test-slow" {
+		t.Fatalf("expected test-slow to be recorded as skipped, got %+v", r.Skipped)
+	}
+}
+
+func TestPipeline_DegradesWhenLaterStageIsUnavailable(t *testing.T) {
+	unsure := &fakeStage{name: "test-bert", result: &models.ClassificationResult{LabelID: "DB_ERROR", Confidence: 0.1}}
+	down := &fakeStage{name: "test-llm", err: ErrCircuitOpen}
+	p := &Pipeline{steps: []step{
+		{stage: unsure, attempts: 1, accept: confidentBERT},
+		{stage: down, attempts: 2, accept: anyResult},
+	}}
+
+	r := p.Classify(models.LogEntry{LogMessage: "x"}, Options{})
+	if r.LabelID != "DB_ERROR" || !r.Degraded {
+		t.Fatalf("expected degraded DB_ERROR, got %+v", r)
+	}
+	if r.DegradedReason != "test-llm circuit open" {
+		t.Fatalf("unexpected reason: %s", r.DegradedReason)
+	}
+	if down.calls != 1 {
+		t.Fatalf("open circuit should not be retried, got %d calls", down.calls)
+	}
+
+	defer func() { degradedMode = true }()
+	degradedMode = false
+	if r := p.Classify(models.LogEntry{LogMessage: "x"}, Options{}); r.LabelID != "UNCLASSIFIED" {
+		t.Fatalf("expected UNCLASSIFIED with degraded mode off, got %s", r.LabelID)
+	}
+}
+
+func TestPipeline_ExplainTracesEveryStage(t *testing.T) {
+	unsure := &fakeStage{name: "test-bert", result: &models.ClassificationResult{LabelID: "DB_ERROR", Confidence: 0.1}}
+	sure := &fakeStage{name: "test-llm", result: &models.ClassificationResult{LabelID: "WORKFLOW_ERROR", Confidence: 0.9}}
+	p := &Pipeline{steps: []step{
+		{stage: unsure, attempts: 1, accept: confidentBERT},
+		{stage: sure, attempts: 1, accept: anyResult},
+	}}
+
+	if r := p.Classify(models.LogEntry{LogMessage: "x"}, Options{}); r.Trace != nil {
+		t.Fatalf("trace should only be attached in explain mode")
+	}
+
+	r := p.Classify(models.LogEntry{LogMessage: "x"}, Options{Explain: true})
+	if len(r.Trace) != 2 {
+		t.Fatalf("expected 2 trace entries, got %+v", r.Trace)
+	}
+	if tr := r.Trace[0]; tr.Outcome != "rejected" || tr.Rule != "confidence 0.10 < DB_ERROR threshold 0.20" {
+		t.Errorf("unexpected first trace entry: %+v", tr)
+	}
+	if tr := r.Trace[1]; tr.Outcome != "accepted" || tr.LabelID != "WORKFLOW_ERROR" {
+		t.Errorf("unexpected second trace entry: %+v", tr)
+	}
+}
@@ -0,0 +1,114 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"log-classifier/internal/config"
+	"log-classifier/internal/metrics"
+	"log-classifier/internal/models"
+	"log-classifier/internal/plugin"
+	"time"
+)
+
+const defaultPluginTimeout = 2 * time.Second
+
+// plugins are the running plugin stages in config order.
+var plugins []*pluginStage
+
+// ConfigurePlugins starts the plugin subprocesses, replacing any that
+// are running. It must be called before the server starts handling
+// requests, and before routes, shadow and ensemble pipelines are
+// configured so that they can use the plugin stages.
+func ConfigurePlugins(cfgs []config.PluginConfig) error {
+	started := make([]*pluginStage, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		env := make([]string, 0, len(cfg.Env))
+		for k, v := range cfg.Env {
+			env = append(env, k+"="+v)
+		}
+		name := cfg.Name
+		p, err := plugin.Start(plugin.Config{
+			Name:      name,
+			Command:   cfg.Command,
+			Env:       env,
+			OnRestart: func() { metrics.PluginRestarts.WithLabelValues(name).Inc() },
+		})
+		if err != nil {
+			for _, s := range started {
+				s.proc.Close()
+			}
+			return err
+		}
+
+		s := &pluginStage{
+			name:    name,
+			before:  cfg.Before,
+			timeout: cfg.Timeout,
+			proc:    p,
+			cb:      NewCircuitBreaker("plugin/"+name, 3, 5*time.Second),
+		}
+		if s.before == "" {
+			s.before = "bert"
+		}
+		if s.timeout == 0 {
+			s.timeout = defaultPluginTimeout
+		}
+		started = append(started, s)
+	}
+
+	ClosePlugins()
+	plugins = started
+	primaryPipeline = newDefaultPipeline()
+	return nil
+}
+
+// ClosePlugins stops the plugin subprocesses.
+func ClosePlugins() {
+	for _, s := range plugins {
+		s.proc.Close()
+	}
+	plugins = nil
+}
+
+func pluginByName(name string) (*pluginStage, bool) {
+	for _, s := range plugins {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// pluginStage calls a plugin subprocess through its own breaker, like
+// the HTTP stages.
+type pluginStage struct {
+	name    string
+	before  string
+	timeout time.Duration
+	proc    *plugin.Plugin
+	cb      *CircuitBreaker
+}
+
+func (s *pluginStage) Name() string { return s.name }
+
+func (s *pluginStage) breaker() *CircuitBreaker { return s.cb }
+
+// Classify checks the answer outside the breaker: like a malformed LLM
+// reply, a confidence out of range is the plugin's fault, not a sign that
+// it is down.
+func (s *pluginStage) Classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	resp, err := CallWithBreaker(breakerFor(ctx, s.cb), func() (plugin.Response, error) {
+		return s.proc.Classify(ctx, entry.Source, entry.LogMessage)
+	})
+	if err != nil || resp.LabelID == "" {
+		return nil, err
+	}
+	if resp.Confidence < 0 || resp.Confidence > 1 {
+		return nil, fmt.Errorf("plugin %s: confidence %v is not between 0 and 1", s.name, resp.Confidence)
+	}
+	return &models.ClassificationResult{
+		LabelID:    resp.LabelID,
+		Classifier: s.name,
+		Confidence: resp.Confidence,
+	}, nil
+}
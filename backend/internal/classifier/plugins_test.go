@@ -0,0 +1,100 @@
+package classifier
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log-classifier/internal/config"
+	"log-classifier/internal/models"
+	"log-classifier/internal/plugin"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary act as a plugin when CLASSIFIER_PLUGIN is
+// set: it labels each message with its first word, answers a confidence
+// of 5 for messages ending in "!", and never answers "hang".
+func TestMain(m *testing.M) {
+	if os.Getenv("CLASSIFIER_PLUGIN") != "" {
+		in := bufio.NewScanner(os.Stdin)
+		out := json.NewEncoder(os.Stdout)
+		for in.Scan() {
+			var req plugin.Request
+			json.Unmarshal(in.Bytes(), &req)
+			if req.LogMessage == "hang" {
+				continue
+			}
+			confidence := 0.75
+			if strings.HasSuffix(req.LogMessage, "!") {
+				confidence = 5
+			}
+			out.Encode(plugin.Response{ID: req.ID, LabelID: strings.Fields(req.LogMessage)[0], Confidence: confidence})
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func usePlugin(t *testing.T, cfg config.PluginConfig) {
+	t.Helper()
+	cfg.Command = []string{os.Args[0], "-test.run=^$"}
+	cfg.Env = map[string]string{"CLASSIFIER_PLUGIN": "1"}
+	if err := ConfigurePlugins([]config.PluginConfig{cfg}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	t.Cleanup(func() { ConfigurePlugins(nil) })
+}
+
+func TestPluginStage_Classify(t *testing.T) {
+	usePlugin(t, config.PluginConfig{Name: "java-parser"})
+
+	var names []string
+	for _, s := range primaryPipeline.steps {
+		names = append(names, s.stage.Name())
+	}
+	if got := strings.Join(names, ","); got != "regex,java-parser,bert,llm" {
+		t.Fatalf("unexpected pipeline %s", got)
+	}
+
+	s, ok := stageByName("java-parser")
+	if !ok || s.timeout != defaultPluginTimeout {
+		t.Fatalf("expected the plugin stage with the default timeout, got %+v", s)
+	}
+	run := s.run(t.Context(), models.LogEntry{Source: "app", LogMessage: "DB_ERROR deadlock"})
+	if r := run.result; run.err != nil || r.LabelID != "DB_ERROR" || r.Label != "Database Error" || r.Classifier != "java-parser" || r.Confidence != 0.75 {
+		t.Fatalf("unexpected result %+v, %v", r, run.err)
+	}
+
+	for range 3 {
+		run = s.run(t.Context(), models.LogEntry{Source: "app", LogMessage: "DB_ERROR deadlock!"})
+		if run.result != nil || run.err == nil || !strings.Contains(run.err.Error(), "confidence 5 is not between 0 and 1") {
+			t.Fatalf("expected the out of range confidence to be rejected, got %+v, %v", run.result, run.err)
+		}
+	}
+	if p, _ := pluginByName("java-parser"); p.cb.State() != StateClosed {
+		t.Fatalf("invalid answers must not open the breaker, got %s", p.cb.State())
+	}
+}
+
+func TestPluginStage_TimeoutsOpenTheBreaker(t *testing.T) {
+	usePlugin(t, config.PluginConfig{Name: "slow", Timeout: 20 * time.Millisecond, Before: "llm"})
+
+	// the step timeout covers its retries, so each run is one failure
+	s, _ := stageByName("slow")
+	for range 3 {
+		run := s.run(t.Context(), models.LogEntry{LogMessage: "hang"})
+		if run.err == nil {
+			t.Fatalf("expected a timeout")
+		}
+	}
+	p, _ := pluginByName("slow")
+	if p.cb.State() != StateOpen {
+		t.Fatalf("expected the plugin breaker to open, got %s", p.cb.State())
+	}
+	run := s.run(t.Context(), models.LogEntry{LogMessage: "INFO ok"})
+	if !errors.Is(run.err, ErrCircuitOpen) {
+		t.Fatalf("expected the open breaker to reject calls, got %v", run.err)
+	}
+}
@@ -0,0 +1,89 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"log-classifier/internal/config"
+	"log-classifier/internal/metrics"
+	"log-classifier/internal/models"
+	"log-classifier/internal/redact"
+)
+
+// defaultRedactStages are the stages that send messages out of the
+// process.
+var defaultRedactStages = []string{"bert", "llm", "candidate"}
+
+var (
+	redactor     *redact.Redactor
+	redactStages map[string]bool
+)
+
+func init() {
+	r, err := redact.New(redact.Builtin())
+	if err != nil {
+		panic(fmt.Sprintf("built-in redaction detectors: %v", err))
+	}
+	redactor = r
+	redactStages = stageSet(defaultRedactStages)
+}
+
+// ConfigureRedaction chooses the detectors and the stages whose input is
+// redacted. It must be called before the server starts handling requests,
+// after ConfigurePlugins so that plugin stages can be redacted.
+func ConfigureRedaction(cfg config.RedactConfig) error {
+	for _, name := range cfg.Stages {
+		if !knownStage(name) {
+			// a misspelled stage would silently go unredacted
+			return fmt.Errorf("redact.stages: unknown stage %q", name)
+		}
+	}
+
+	builtin := make(map[string]redact.Detector)
+	var detectors []redact.Detector
+	for _, d := range redact.Builtin() {
+		builtin[d.Name] = d
+		if len(cfg.Detectors) == 0 {
+			detectors = append(detectors, d)
+		}
+	}
+	for _, name := range cfg.Detectors {
+		d, ok := builtin[name]
+		if !ok {
+			return fmt.Errorf("redact.detectors: unknown detector %q", name)
+		}
+		detectors = append(detectors, d)
+	}
+	for _, d := range cfg.Custom {
+		detectors = append(detectors, redact.Detector{Name: d.Name, Pattern: d.Pattern, Placeholder: d.Placeholder})
+	}
+
+	r, err := redact.New(detectors)
+	if err != nil {
+		return err
+	}
+	redactor = r
+	redactStages = stageSet(cfg.Stages)
+	return nil
+}
+
+func stageSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+// redactEntry replaces sensitive spans in the message if the stage's
+// input is redacted.
+func redactEntry(ctx context.Context, stage string, entry models.LogEntry) models.LogEntry {
+	if !redactStages[stage] {
+		return entry
+	}
+	msg, counts := redactor.Redact(entry.LogMessage)
+	for detector, n := range counts {
+		metrics.Redactions.WithLabelValues(metricName(ctx, stage), detector).Add(float64(n))
+	}
+	entry.LogMessage = msg
+	return entry
+}
@@ -0,0 +1,75 @@
+package classifier
+
+import (
+	"log-classifier/internal/config"
+	"log-classifier/internal/metrics"
+	"log-classifier/internal/models"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRedaction_OnlyForConfiguredStages(t *testing.T) {
+	if err := ConfigureRedaction(config.RedactConfig{Stages: []string{"bert"}}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureRedaction(config.Default().Redact)
+
+	local := &recordingStage{fakeStage: fakeStage{name: "regex"}}
+	remote := &recordingStage{fakeStage: fakeStage{name: "bert"}}
+	p := &Pipeline{steps: []step{
+		{stage: local, attempts: 1, accept: anyResult},
+		{stage: remote, attempts: 1, accept: anyResult},
+	}}
+
+	before := testutil.ToFloat64(metrics.Redactions.WithLabelValues("bert", "credential"))
+	msg := "login failed for bob@example.com with password=hunter2"
+	p.Classify(models.LogEntry{LogMessage: msg}, Options{})
+
+	if local.seen[0] != msg {
+		t.Fatalf("local stage input should not be redacted, got %q", local.seen[0])
+	}
+	if want := "login failed for <EMAIL> with password=<SECRET>"; remote.seen[0] != want {
+		t.Fatalf("expected remote stage to see %q, got %q", want, remote.seen[0])
+	}
+	if got := testutil.ToFloat64(metrics.Redactions.WithLabelValues("bert", "credential")) - before; got != 1 {
+		t.Fatalf("expected one credential redaction counted, got %v", got)
+	}
+}
+
+func TestRedaction_BeforeNormalization(t *testing.T) {
+	if err := ConfigureNormalization(config.NormalizeConfig{Enabled: true}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	defer ConfigureNormalization(config.NormalizeConfig{})
+
+	got := stageEntry(t.Context(), "llm", models.LogEntry{LogMessage: "card 4111-1111-1111-1111 charged 20 times"}).LogMessage
+	if want := "card <CREDIT_CARD> charged <NUM> times"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestConfigureRedaction_SelectsDetectors(t *testing.T) {
+	defer ConfigureRedaction(config.Default().Redact)
+
+	if err := ConfigureRedaction(config.RedactConfig{Detectors: []string{"nope"}}); err == nil {
+		t.Fatalf("expected unknown detector to be rejected")
+	}
+	if err := ConfigureRedaction(config.RedactConfig{Stages: []string{"bert", "LLM"}}); err == nil || !strings.Contains(err.Error(), `"LLM"`) {
+		t.Fatalf("expected unknown stage to be rejected, got %v", err)
+	}
+
+	err := ConfigureRedaction(config.RedactConfig{
+		Stages:    []string{"llm"},
+		Detectors: []string{"email"},
+		Custom:    []config.RedactDetector{{Name: "ticket", Pattern: `TICKET-\d+`, Placeholder: "<TICKET>"}},
+	})
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	got := stageEntry(t.Context(), "llm", models.LogEntry{LogMessage: "TICKET-7 from a@b.io password=x"}).LogMessage
+	if want := "<TICKET> from <EMAIL> password=x"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
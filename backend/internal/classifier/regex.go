@@ -1,69 +1,137 @@
 package classifier
 
 import (
+	"context"
+	"fmt"
+	"log-classifier/internal/metrics"
 	"log-classifier/internal/models"
-	"regexp"
+	"log-classifier/internal/rules"
+	"log-classifier/internal/taxonomy"
+	"sync/atomic"
+	"time"
 )
 
-type regexRule struct {
-	pattern *regexp.Regexp
-	labelID string
-	label   string
+var (
+	regexRules atomic.Pointer[rules.Compiled]
+	ruleHits   = rules.NewHitTracker()
+)
+
+func init() {
+	c, err := rules.Compile(rules.Default(), taxonomy.Current())
+	if err != nil {
+		panic(fmt.Sprintf("built-in regex rules: %v", err))
+	}
+	regexRules.Store(c)
 }
 
-var regexRules = []regexRule{
-	{
-		pattern: regexp.MustCompile(`(?i)User \w+ logged (in|out)`),
-		labelID: "USER_ACTION",
-		label:   "User Action",
-	},
-	{
-		pattern: regexp.MustCompile(`(?i)Account with ID .+ created by .+`),
-		labelID: "USER_ACTION",
-		label:   "User Action",
-	},
-	{
-		pattern: regexp.MustCompile(`(?i)Backup (started|ended) at .+`),
-		labelID: "SYSTEM_NOTIFICATION",
-		label:   "System Notification",
-	},
-	{
-		pattern: regexp.MustCompile(`(?i)Backup completed successfully`),
-		labelID: "SYSTEM_NOTIFICATION",
-		label:   "System Notification",
-	},
-	{
-		pattern: regexp.MustCompile(`(?i)System updated to version .+`),
-		labelID: "SYSTEM_NOTIFICATION",
-		label:   "System Notification",
-	},
-	{
-		pattern: regexp.MustCompile(`(?i)File .+ uploaded successfully by user .+`),
-		labelID: "SYSTEM_NOTIFICATION",
-		label:   "System Notification",
-	},
-	{
-		pattern: regexp.MustCompile(`(?i)Disk cleanup completed successfully`),
-		labelID: "SYSTEM_NOTIFICATION",
-		label:   "System Notification",
-	},
-	{
-		pattern: regexp.MustCompile(`(?i)System reboot initiated by user .+`),
-		labelID: "SYSTEM_NOTIFICATION",
-		label:   "System Notification",
-	},
+// SetRegexRules swaps in a validated rule set. Entries already being
+// classified finish with the rules they started with.
+func SetRegexRules(c *rules.Compiled) {
+	regexRules.Store(c)
 }
 
-func ClassifyWithRegex(msg string) *models.ClassificationResult {
-	for _, rule := range regexRules {
-		if rule.pattern.MatchString(msg) {
-			return &models.ClassificationResult{
-				LabelID:    rule.labelID,
-				Label:      rule.label,
-				Classifier: "regex",
-				Confidence: 0.95,
-			}
+// DeadRules lists the enabled regex rules that have not fired within window.
+func DeadRules(window time.Duration) rules.DeadReport {
+	return ruleHits.Dead(regexRules.Load(), window)
+}
+
+func recordRuleHit(rule *rules.CompiledRule) {
+	metrics.RuleActions.WithLabelValues(rule.ActionName()).Inc()
+	metrics.RuleHits.WithLabelValues(ruleHits.Record(rule.ID)).Inc()
+}
+
+// ruleActions carries the effects of drop, tag, route and rewrite rules
+// back to the pipeline.
+type ruleActions struct {
+	message string // rewritten message, empty if unchanged
+	tags    []string
+	route   string
+	drop    bool
+}
+
+// actionStage is implemented by stages whose rules can do more than label
+// an entry.
+type actionStage interface {
+	Stage
+	classifyWithActions(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, *ruleActions, error)
+}
+
+// evaluateRegex runs the rule set on msg. The result is set for label and
+// drop rules; actions is nil when no rule other than a label rule fired.
+// The rules that fired are recorded as hits if record is set.
+func evaluateRegex(msg string, record bool) (*models.ClassificationResult, *ruleActions) {
+	out := regexRules.Load().Evaluate(msg)
+
+	var actions *ruleActions
+	if len(out.Applied) > 0 || (out.Rule != nil && out.Rule.ActionName() != rules.ActionLabel) {
+		actions = &ruleActions{tags: out.Tags}
+		if out.Message != msg {
+			actions.message = out.Message
+		}
+	}
+	if record {
+		for _, r := range out.Applied {
+			recordRuleHit(r)
 		}
 	}
-	return nil
+
+	rule := out.Rule
+	if rule == nil {
+		return nil, actions
+	}
+	if record {
+		recordRuleHit(rule)
+	}
+
+	switch rule.ActionName() {
+	case rules.ActionRoute:
+		actions.route = rule.Route
+		return nil, actions
+	case rules.ActionDrop:
+		actions.drop = true
+		label, ok := taxonomy.Current().Lookup(rule.Label)
+		if !ok {
+			label, _ = taxonomy.Current().Lookup(taxonomy.Unclassified)
+		}
+		return &models.ClassificationResult{
+			LabelID:     label.ID,
+			Label:       label.Name,
+			Classifier:  "regex",
+			Confidence:  rule.Confidence,
+			MatchedRule: rule.ID,
+			Dropped:     true,
+		}, actions
+	}
+
+	ex := rule.Extract(out.Message)
+	label, ok := taxonomy.Current().Lookup(ex.LabelID)
+	if !ok {
+		// a dynamic label that is not in the taxonomy falls back to the
+		// rule's static label
+		label, _ = taxonomy.Current().Lookup(rule.Label)
+	}
+
+	return &models.ClassificationResult{
+		LabelID:     label.ID,
+		Label:       label.Name,
+		Classifier:  "regex",
+		Confidence:  rule.Confidence,
+		MatchedRule: rule.ID,
+		Entities:    ex.Entities,
+		SubType:     ex.SubType,
+	}, actions
+}
+
+// ClassifyWithRegex returns the result of the first label rule matching
+// msg, ignoring the other rule actions.
+func ClassifyWithRegex(msg string) *models.ClassificationResult {
+	return regexLabel(msg, true)
+}
+
+func regexLabel(msg string, record bool) *models.ClassificationResult {
+	result, actions := evaluateRegex(msg, record)
+	if actions != nil && actions.drop {
+		return nil
+	}
+	return result
 }
@@ -0,0 +1,130 @@
+package classifier
+
+import (
+	"context"
+	"log-classifier/internal/models"
+	"log-classifier/internal/rules"
+	"log-classifier/internal/taxonomy"
+	"testing"
+	"time"
+)
+
+func useRules(t *testing.T, src string) {
+	t.Helper()
+	rs, err := rules.Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	c, err := rules.Compile(rs, taxonomy.Current())
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	prev := regexRules.Load()
+	SetRegexRules(c)
+	t.Cleanup(func() { SetRegexRules(prev) })
+}
+
+type recordingStage struct {
+	fakeStage
+	seen []string
+}
+
+func (r *recordingStage) Classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	r.seen = append(r.seen, entry.LogMessage)
+	return r.fakeStage.Classify(ctx, entry)
+}
+
+func TestPipeline_RuleActions(t *testing.T) {
+	useRules(t, `
+rules:
+  - id: heartbeat
+    pattern: '^heartbeat'
+    action: drop
+  - id: mask-ip
+    pattern: '\d+\.\d+\.\d+\.\d+'
+    action: rewrite
+    rewrite: '<IP>'
+  - id: payments
+    pattern: '^payments:'
+    action: tag
+    tags: [payments]
+  - id: security
+    pattern: 'intrusion'
+    action: route
+    route: security
+`)
+
+	next := &recordingStage{fakeStage: fakeStage{name: "test-next", result: &models.ClassificationResult{LabelID: "DB_ERROR", Confidence: 0.9}}}
+	routed := &recordingStage{fakeStage: fakeStage{name: "test-security", result: &models.ClassificationResult{LabelID: "AUTH_ERROR", Confidence: 0.9}}}
+
+	p := &Pipeline{steps: []step{
+		{stage: regexStage{}, attempts: 1, accept: acceptAbove("regex")},
+		{stage: next, attempts: 1, accept: anyResult},
+	}}
+	defer func(r map[string]*Pipeline) { routes = r }(routes)
+	routes = map[string]*Pipeline{"security": {name: "security", steps: []step{{stage: routed, attempts: 1, accept: anyResult}}}}
+
+	r := p.Classify(models.LogEntry{LogMessage: "heartbeat ok"}, Options{})
+	if !r.Dropped || next.calls != 0 {
+		t.Fatalf("expected entry to be dropped before later stages, got %+v", r)
+	}
+
+	r = p.Classify(models.LogEntry{LogMessage: "payments: connection to 10.0.0.1 refused"}, Options{})
+	if r.LabelID != "DB_ERROR" || len(r.Tags) != 1 || r.Tags[0] != "payments" {
+		t.Fatalf("expected tagged DB_ERROR, got %+v", r)
+	}
+	if got := next.seen[len(next.seen)-1]; got != "payments: connection to <IP> refused" {
+		t.Fatalf("later stage saw %q", got)
+	}
+
+	r = p.Classify(models.LogEntry{LogMessage: "intrusion detected"}, Options{})
+	if r.LabelID != "AUTH_ERROR" || routed.calls != 1 {
+		t.Fatalf("expected entry to be routed, got %+v", r)
+	}
+}
+
+func TestClassifyWithRegex_Entities(t *testing.T) {
+	r := ClassifyWithRegex("User admin123 logged in.")
+	if r == nil || r.LabelID != "USER_ACTION" {
+		t.Fatalf("expected USER_ACTION, got %+v", r)
+	}
+	if r.Entities["user"] != "admin123" || r.SubType != "login" {
+		t.Fatalf("unexpected extraction: %v %q", r.Entities, r.SubType)
+	}
+}
+
+func TestDeadRules_ExcludesRulesThatFired(t *testing.T) {
+	useRules(t, `
+rules:
+  - {id: tag-fired, pattern: 'disk', action: tag, tags: [storage]}
+  - {id: label-fired, pattern: 'full', label: INFO}
+  - {id: never-fired, pattern: 'never', label: INFO}
+`)
+	evaluateRegex("disk full", true)
+
+	report := DeadRules(time.Hour)
+	if len(report.Rules) != 1 || report.Rules[0].ID != "never-fired" {
+		t.Fatalf("expected only never-fired to be dead, got %+v", report.Rules)
+	}
+}
+
+func TestClassifyWithRegex_UnknownRenderedLabelFallsBack(t *testing.T) {
+	reg, err := taxonomy.New(taxonomy.Current().Labels(), nil, true)
+	if err != nil {
+		t.Fatalf("taxonomy: %v", err)
+	}
+	prev := taxonomy.Current()
+	taxonomy.Set(reg)
+	defer taxonomy.Set(prev)
+	useRules(t, `
+rules:
+  - {id: by-kind, pattern: '^(?P<kind>[A-Z_]+) failure', label: WORKFLOW_ERROR, label_template: '${kind}'}
+`)
+
+	if r := ClassifyWithRegex("DB_ERROR failure"); r == nil || r.LabelID != "DB_ERROR" {
+		t.Fatalf("expected the rendered label, got %+v", r)
+	}
+	if r := ClassifyWithRegex("DISK_ERROR failure"); r == nil || r.LabelID != "WORKFLOW_ERROR" {
+		t.Fatalf("expected the rule's label, got %+v", r)
+	}
+}
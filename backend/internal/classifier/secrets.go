@@ -0,0 +1,90 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"log-classifier/internal/config"
+	"log-classifier/internal/metrics"
+	"log-classifier/internal/models"
+	"log-classifier/internal/redact"
+	"log-classifier/internal/taxonomy"
+)
+
+// secretDetector is nil unless secret-leak detection is enabled, in which
+// case the secret stage runs first in the primary pipeline and before the
+// ensemble votes. Routed entries have passed through it in the primary
+// pipeline.
+var secretDetector *redact.Redactor
+
+// ConfigureSecretDetection enables the secret stage. The taxonomy must
+// have a SECRET_LEAK label. It must be called before the server starts
+// handling requests.
+func ConfigureSecretDetection(cfg config.SecretsConfig) error {
+	if !cfg.Enabled {
+		secretDetector = nil
+		primaryPipeline = newDefaultPipeline()
+		return nil
+	}
+	if _, ok := taxonomy.Current().Lookup(taxonomy.SecretLeak); !ok {
+		return fmt.Errorf("secrets: the taxonomy has no %s label", taxonomy.SecretLeak)
+	}
+
+	detectors := redact.Secrets()
+	for _, d := range cfg.Custom {
+		detectors = append(detectors, redact.Detector{Name: d.Name, Pattern: d.Pattern})
+	}
+	r, err := redact.New(detectors)
+	if err != nil {
+		return err
+	}
+	secretDetector = r
+	primaryPipeline = newDefaultPipeline()
+	return nil
+}
+
+// checkSecrets runs the secret stage for pipelines that do not start with
+// it. The result is nil if secret detection is off or found nothing.
+func checkSecrets(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, []models.StageTrace) {
+	if secretDetector == nil {
+		return nil, nil
+	}
+	s, _ := stageByName("secret")
+	run := s.run(ctx, entry)
+	if run.err != nil || run.result == nil {
+		return nil, nil
+	}
+	run.result.LogSource = entry.Source
+	return run.result, []models.StageTrace{s.trace(run, "accepted", "secret leaked")}
+}
+
+// secretStage flags entries that leak credentials. The result names the
+// kind of each secret and where it is, never its value.
+type secretStage struct{}
+
+func (secretStage) Name() string { return "secret" }
+
+func (secretStage) Classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	d := secretDetector
+	if d == nil {
+		return nil, nil
+	}
+	findings := d.Find(entry.LogMessage)
+	if len(findings) == 0 {
+		return nil, nil
+	}
+
+	result := &models.ClassificationResult{
+		LabelID:    taxonomy.SecretLeak,
+		Classifier: "secret",
+		Confidence: 1.0,
+		SubType:    findings[0].Detector,
+	}
+	for _, f := range findings {
+		// an alerting counter: a leak is counted once, by the live run
+		if !isShadow(ctx) {
+			metrics.SecretLeaks.WithLabelValues(f.Detector).Inc()
+		}
+		result.Secrets = append(result.Secrets, models.SecretFinding{Type: f.Detector, Start: f.Start, End: f.End})
+	}
+	return result, nil
+}
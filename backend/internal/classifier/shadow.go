@@ -0,0 +1,215 @@
+package classifier
+
+import (
+	"context"
+	"fmt"
+	"log-classifier/internal/config"
+	"log-classifier/internal/metrics"
+	"log-classifier/internal/models"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShadowDisagreement records a sampled entry where the shadow pipeline
+// did not agree with the primary one.
+type ShadowDisagreement struct {
+	Time    time.Time                   `json:"time"`
+	Source  string                      `json:"source"`
+	Message string                      `json:"log_message"`
+	Fields  []string                    `json:"fields"`
+	Primary models.ClassificationResult `json:"primary"`
+	Shadow  models.ClassificationResult `json:"shadow"`
+}
+
+type shadowRunner struct {
+	pipeline  *Pipeline
+	percent   int
+	tolerance float64
+	inFlight  chan struct{}
+
+	mu       sync.Mutex
+	log      []ShadowDisagreement // ring buffer
+	next     int
+	full     bool
+	agreed   uint64
+	compared uint64
+}
+
+var shadow atomic.Pointer[shadowRunner]
+
+// ConfigureShadow builds the shadow pipeline from cfg. A zero sample
+// percentage disables shadow classification.
+func ConfigureShadow(cfg config.ShadowConfig) error {
+	if cfg.SamplePercent == 0 {
+		shadow.Store(nil)
+		return nil
+	}
+
+	// breakers replaced by a reconfiguration are no longer called
+	shadowBreakersMu.Lock()
+	clear(shadowBreakers)
+	shadowBreakersMu.Unlock()
+
+	p := &Pipeline{name: "shadow", shadow: true}
+	for _, name := range cfg.Pipeline {
+		if name == "candidate" {
+			if cfg.CandidateURL == "" {
+				return fmt.Errorf("shadow: candidate stage requires candidate_url")
+			}
+			p.steps = append(p.steps, step{
+				stage:    candidateStage{url: cfg.CandidateURL, cb: NewCircuitBreaker("candidate", 5, 10*time.Second)},
+				timeout:  4 * time.Second,
+				attempts: 2,
+				accept:   confidentBERT,
+			})
+			continue
+		}
+		s, ok := stageByName(name)
+		if !ok {
+			return fmt.Errorf("shadow: unknown stage %q", name)
+		}
+		p.steps = append(p.steps, s)
+	}
+
+	shadow.Store(&shadowRunner{
+		pipeline:  p,
+		percent:   cfg.SamplePercent,
+		tolerance: cfg.ConfidenceTolerance,
+		inFlight:  make(chan struct{}, cfg.MaxInFlight),
+		log:       make([]ShadowDisagreement, cfg.LogSize),
+	})
+	return nil
+}
+
+type shadowKey struct{}
+
+// withShadow marks ctx as a shadow run, which must not change anything
+// live traffic depends on.
+func withShadow(ctx context.Context) context.Context {
+	return context.WithValue(ctx, shadowKey{}, true)
+}
+
+func isShadow(ctx context.Context) bool {
+	return ctx.Value(shadowKey{}) != nil
+}
+
+// metricName labels the metrics of shadow calls apart from live ones.
+func metricName(ctx context.Context, name string) string {
+	if isShadow(ctx) {
+		return "shadow/" + name
+	}
+	return name
+}
+
+var (
+	shadowBreakersMu sync.Mutex
+	shadowBreakers   = map[*CircuitBreaker]*CircuitBreaker{}
+)
+
+// breakerFor returns the breaker a call goes through. Shadow calls get
+// their own copy of cb, so that their failures cannot open the live one
+// or show up in /health.
+func breakerFor(ctx context.Context, cb *CircuitBreaker) *CircuitBreaker {
+	if !isShadow(ctx) {
+		return cb
+	}
+	shadowBreakersMu.Lock()
+	defer shadowBreakersMu.Unlock()
+	b, ok := shadowBreakers[cb]
+	if !ok {
+		b = NewCircuitBreaker("shadow/"+cb.name, cb.maxFailures, cb.resetTimeout)
+		shadowBreakers[cb] = b
+	}
+	return b
+}
+
+// shadowClassify runs the shadow pipeline for a sample of entries in the
+// background. It never blocks or alters the primary result.
+func shadowClassify(entry models.LogEntry, primary *models.ClassificationResult) {
+	s := shadow.Load()
+	if s == nil || rand.IntN(100) >= s.percent {
+		return
+	}
+
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		metrics.ShadowComparisons.WithLabelValues("dropped").Inc()
+		return
+	}
+
+	primaryCopy := *primary
+	go func() {
+		defer func() { <-s.inFlight }()
+		shadowResult := s.pipeline.Classify(entry, Options{})
+		s.record(entry, primaryCopy, *shadowResult)
+	}()
+}
+
+func (s *shadowRunner) record(entry models.LogEntry, primary, shadowResult models.ClassificationResult) {
+	fields := compareResults(primary, shadowResult, s.tolerance)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.compared++
+	if len(fields) == 0 {
+		s.agreed++
+		metrics.ShadowComparisons.WithLabelValues("agree").Inc()
+	} else {
+		metrics.ShadowComparisons.WithLabelValues("disagree").Inc()
+		for _, f := range fields {
+			metrics.ShadowDisagreements.WithLabelValues(f).Inc()
+		}
+
+		s.log[s.next] = ShadowDisagreement{
+			Time:    time.Now(),
+			Source:  entry.Source,
+			Message: entry.LogMessage,
+			Fields:  fields,
+			Primary: primary,
+			Shadow:  shadowResult,
+		}
+		s.next = (s.next + 1) % len(s.log)
+		if s.next == 0 {
+			s.full = true
+		}
+	}
+	metrics.ShadowAgreementRate.Set(float64(s.agreed) / float64(s.compared))
+}
+
+// compareResults returns the fields on which two results disagree.
+func compareResults(a, b models.ClassificationResult, tolerance float64) []string {
+	var fields []string
+	if a.LabelID != b.LabelID {
+		fields = append(fields, "label")
+	}
+	if math.Abs(a.Confidence-b.Confidence) > tolerance {
+		fields = append(fields, "confidence")
+	}
+	if a.Classifier != b.Classifier {
+		fields = append(fields, "stage")
+	}
+	return fields
+}
+
+// ShadowDisagreements returns the recorded disagreements, oldest first.
+func ShadowDisagreements() []ShadowDisagreement {
+	s := shadow.Load()
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.full {
+		return append([]ShadowDisagreement(nil), s.log[:s.next]...)
+	}
+	out := make([]ShadowDisagreement, 0, len(s.log))
+	out = append(out, s.log[s.next:]...)
+	return append(out, s.log[:s.next]...)
+}
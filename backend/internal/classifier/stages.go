@@ -0,0 +1,95 @@
+package classifier
+
+import (
+	"context"
+	"log-classifier/internal/llm"
+	"log-classifier/internal/models"
+)
+
+type regexStage struct{}
+
+func (regexStage) Name() string { return "regex" }
+
+// Classify and classifyWithActions only count rule hits for live traffic.
+func (regexStage) Classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	return regexLabel(entry.LogMessage, !isShadow(ctx)), nil
+}
+
+func (regexStage) classifyWithActions(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, *ruleActions, error) {
+	result, actions := evaluateRegex(entry.LogMessage, !isShadow(ctx))
+	return result, actions, nil
+}
+
+type bertStage struct{}
+
+func (bertStage) Name() string { return "bert" }
+
+func (bertStage) breaker() *CircuitBreaker { return bertBreaker }
+
+func (bertStage) Classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	return ClassifyWithBERT(ctx, entry.LogMessage)
+}
+
+type llmStage struct{}
+
+func (llmStage) Name() string { return "llm" }
+
+// breaker is nil when the stage fails over between chat completions
+// providers; providerBreakers reports their states instead.
+func (llmStage) breaker() *CircuitBreaker {
+	switch chain := chatLLM; len(chain) {
+	case 0:
+		return llmBreaker
+	case 1:
+		return chain[0].breaker
+	}
+	return nil
+}
+
+func (llmStage) providerBreakers() map[string]string {
+	chain := chatLLM
+	if len(chain) < 2 {
+		return nil
+	}
+	states := make(map[string]string, len(chain))
+	for _, c := range chain {
+		states[c.name] = c.breaker.State().String()
+	}
+	return states
+}
+
+func (llmStage) Classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	if chain := chatLLM; len(chain) > 0 {
+		return classifyChain(ctx, chain, entry)
+	}
+
+	reserved := serviceOverheadTokens + llm.EstimateTokens(llm.ChatRequest{Messages: []llm.Message{{Content: entry.LogMessage}}})
+	if err := reserveLLM(ctx, entry.Source, reserved); err != nil {
+		return nil, err
+	}
+	result, err := CallLLMWithTimeout(ctx, entry.LogMessage)
+	settleLLM(ctx, entry.Source, reserved, llm.Usage{}, err)
+	return result, err
+}
+
+// candidateStage calls a candidate model that speaks the BERT service
+// protocol. It is only used by shadow pipelines.
+type candidateStage struct {
+	url string
+	cb  *CircuitBreaker
+}
+
+func (candidateStage) Name() string { return "candidate" }
+
+func (s candidateStage) breaker() *CircuitBreaker { return s.cb }
+
+func (s candidateStage) Classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	result, err := CallWithBreaker(breakerFor(ctx, s.cb), func() (*models.ClassificationResult, error) {
+		return callBERT(ctx, s.url, entry.LogMessage)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.ModelVariant = "candidate"
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package classifier
+
+import (
+	"context"
+	"log-classifier/internal/config"
+	"log-classifier/internal/metrics"
+	"log-classifier/internal/models"
+	"log-classifier/internal/taxonomy"
+	"log-classifier/internal/templates"
+)
+
+// templateMiner is nil unless template mining is enabled, in which case
+// the template stage runs between regex and the remote stages.
+var templateMiner *templates.Miner
+
+// ConfigureTemplates enables template mining. It must be called before
+// the server starts handling requests.
+func ConfigureTemplates(cfg config.TemplatesConfig) {
+	if !cfg.Enabled {
+		templateMiner = nil
+	} else {
+		templateMiner = templates.NewMiner(templates.Config{
+			Depth:        cfg.Depth,
+			Similarity:   cfg.Similarity,
+			MaxChildren:  cfg.MaxChildren,
+			MaxTemplates: cfg.MaxTemplates,
+		})
+	}
+	primaryPipeline = newDefaultPipeline()
+}
+
+// Templates returns the learned templates, most frequent first.
+func Templates() []templates.Template {
+	if templateMiner == nil {
+		return nil
+	}
+	return templateMiner.Templates()
+}
+
+// templateStage files the entry under its template and answers with the
+// label already decided for that template, if any. Shadow runs only look
+// the template up, so they never change the learned templates.
+type templateStage struct{}
+
+func (templateStage) Name() string { return "template" }
+
+func (s templateStage) Classify(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	result, _, err := s.file(ctx, entry)
+	return result, err
+}
+
+// file is Classify that also returns the ID of the template the entry was
+// filed under, or 0.
+func (templateStage) file(ctx context.Context, entry models.LogEntry) (*models.ClassificationResult, int, error) {
+	m := templateMiner
+	if m == nil {
+		return nil, 0, nil
+	}
+
+	if isShadow(ctx) {
+		t, ok := m.Match(entry.LogMessage)
+		if !ok || t.LabelID == "" {
+			return nil, 0, nil
+		}
+		return &models.ClassificationResult{
+			LabelID:    t.LabelID,
+			Classifier: "template",
+			Confidence: t.Confidence,
+			TemplateID: t.ID,
+		}, 0, nil
+	}
+
+	t, ok := m.Add(entry.LogMessage)
+	metrics.Templates.Set(float64(m.Len()))
+	if !ok {
+		metrics.TemplateLookups.WithLabelValues("full").Inc()
+		return nil, 0, nil
+	}
+	if t.Ambiguous {
+		metrics.TemplateLookups.WithLabelValues("ambiguous").Inc()
+		return nil, t.ID, nil
+	}
+	if t.LabelID == "" {
+		metrics.TemplateLookups.WithLabelValues("unlabeled").Inc()
+		return nil, t.ID, nil
+	}
+
+	metrics.TemplateLookups.WithLabelValues("reused").Inc()
+	return &models.ClassificationResult{
+		LabelID:    t.LabelID,
+		Classifier: "template",
+		Confidence: t.Confidence,
+		TemplateID: t.ID,
+	}, t.ID, nil
+}
+
+// learnTemplate sets template id, which the entry was filed under, on
+// result. A result decided by a later stage becomes the template's label,
+// unless it is only a best effort (degraded, unclassified or cut short by
+// the time budget) or too unsure for the template stage to accept.
+func learnTemplate(id int, result *models.ClassificationResult) {
+	m := templateMiner
+	if m == nil {
+		return
+	}
+	result.TemplateID = id
+
+	if result.Classifier == "template" || result.Degraded || len(result.Skipped) > 0 ||
+		result.LabelID == taxonomy.Unclassified {
+		return
+	}
+	if ok, _ := acceptAbove("template")(result); !ok {
+		return
+	}
+	if m.SetLabel(id, result.LabelID, result.Confidence, result.Classifier) {
+		metrics.TemplateLookups.WithLabelValues("learned").Inc()
+	}
+}
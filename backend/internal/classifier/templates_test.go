@@ -0,0 +1,127 @@
+package classifier
+
+import (
+	"context"
+	"log-classifier/internal/config"
+	"log-classifier/internal/models"
+	"testing"
+)
+
+func TestTemplateStage_ReusesLearnedLabel(t *testing.T) {
+	ConfigureTemplates(config.TemplatesConfig{Enabled: true})
+	defer ConfigureTemplates(config.TemplatesConfig{})
+
+	remote := &fakeStage{name: "remote", result: &models.ClassificationResult{LabelID: "DB_ERROR", Classifier: "bert", Confidence: 0.8}}
+	p := &Pipeline{steps: []step{
+		{stage: templateStage{}, attempts: 1, accept: acceptAbove("template")},
+		{stage: remote, attempts: 1, accept: anyResult},
+	}}
+
+	first := p.Classify(models.LogEntry{LogMessage: "Query on shard 3 timed out after 30s"}, Options{})
+	// generalizes the template, so its label is verified again
+	p.Classify(models.LogEntry{LogMessage: "Query on shard 9 timed out after 12s"}, Options{})
+	third := p.Classify(models.LogEntry{LogMessage: "Query on shard 4 timed out after 5s"}, Options{})
+
+	if remote.calls != 2 {
+		t.Fatalf("expected the remote stage to run twice, ran %d times", remote.calls)
+	}
+	if first.TemplateID == 0 || third.TemplateID != first.TemplateID {
+		t.Fatalf("expected a shared template, got %d and %d", first.TemplateID, third.TemplateID)
+	}
+	if third.Classifier != "template" || third.LabelID != "DB_ERROR" || third.Label == "" || third.Confidence != 0.8 {
+		t.Fatalf("expected reused DB_ERROR, got %+v", third)
+	}
+
+	ts := Templates()
+	if len(ts) != 1 || ts[0].Count != 3 || ts[0].LabelID != "DB_ERROR" || ts[0].Classifier != "bert" {
+		t.Fatalf("unexpected templates: %+v", ts)
+	}
+}
+
+func TestTemplateStage_DoesNotLearnBestEffortResults(t *testing.T) {
+	ConfigureTemplates(config.TemplatesConfig{Enabled: true})
+	defer ConfigureTemplates(config.TemplatesConfig{})
+
+	remote := &fakeStage{name: "remote"}
+	p := &Pipeline{steps: []step{
+		{stage: templateStage{}, attempts: 1, accept: acceptAbove("template")},
+		{stage: remote, attempts: 1, accept: anyResult},
+	}}
+
+	p.Classify(models.LogEntry{LogMessage: "unknown thing happened"}, Options{})
+	p.Classify(models.LogEntry{LogMessage: "unknown thing happened"}, Options{})
+	if remote.calls != 2 {
+		t.Fatalf("an unclassified result must not be reused, remote ran %d times", remote.calls)
+	}
+}
+
+func TestTemplateStage_OppositeMessagesShareNoLabel(t *testing.T) {
+	ConfigureTemplates(config.TemplatesConfig{Enabled: true})
+	defer ConfigureTemplates(config.TemplatesConfig{})
+
+	remote := &byMessageStage{name: "remote", results: map[string]*models.ClassificationResult{
+		"Payment 42 for alice succeeded": {LabelID: "USER_ACTION", Classifier: "bert", Confidence: 0.9},
+		"Payment 43 for alice failed":    {LabelID: "WORKFLOW_ERROR", Classifier: "bert", Confidence: 0.9},
+	}}
+	p := &Pipeline{steps: []step{
+		{stage: templateStage{}, attempts: 1, accept: acceptAbove("template")},
+		{stage: remote, attempts: 1, accept: anyResult},
+	}}
+
+	for _, msg := range []string{"Payment 42 for alice succeeded", "Payment 43 for alice failed", "Payment 42 for alice succeeded"} {
+		r := p.Classify(models.LogEntry{LogMessage: msg}, Options{})
+		if want := remote.results[msg].LabelID; r.LabelID != want || r.Classifier != "bert" {
+			t.Fatalf("%q: expected %s from bert, got %s from %s", msg, want, r.LabelID, r.Classifier)
+		}
+	}
+	if ts := Templates(); len(ts) != 1 || !ts[0].Ambiguous {
+		t.Fatalf("expected one ambiguous template, got %+v", ts)
+	}
+}
+
+func TestTemplateStage_DoesNotLearnUnsureResults(t *testing.T) {
+	ConfigureTemplates(config.TemplatesConfig{Enabled: true})
+	defer ConfigureTemplates(config.TemplatesConfig{})
+
+	remote := &fakeStage{name: "remote", result: &models.ClassificationResult{LabelID: "DB_ERROR", Classifier: "bert", Confidence: 0.3}}
+	p := &Pipeline{steps: []step{
+		{stage: templateStage{}, attempts: 1, accept: acceptAbove("template")},
+		{stage: remote, attempts: 1, accept: anyResult},
+	}}
+
+	p.Classify(models.LogEntry{LogMessage: "Query on shard 3 timed out"}, Options{})
+	p.Classify(models.LogEntry{LogMessage: "Query on shard 3 timed out"}, Options{})
+	if remote.calls != 2 {
+		t.Fatalf("an unsure result must not be reused, remote ran %d times", remote.calls)
+	}
+}
+
+// byMessageStage answers with the result configured for the message.
+type byMessageStage struct {
+	name    string
+	results map[string]*models.ClassificationResult
+}
+
+func (s *byMessageStage) Name() string { return s.name }
+
+func (s *byMessageStage) Classify(_ context.Context, entry models.LogEntry) (*models.ClassificationResult, error) {
+	r, ok := s.results[entry.LogMessage]
+	if !ok {
+		return nil, nil
+	}
+	c := *r
+	return &c, nil
+}
+
+func TestConfigureTemplates_AddsStageToPrimaryPipeline(t *testing.T) {
+	ConfigureTemplates(config.TemplatesConfig{Enabled: true})
+	defer ConfigureTemplates(config.TemplatesConfig{})
+
+	var names []string
+	for _, s := range primaryPipeline.steps {
+		names = append(names, s.stage.Name())
+	}
+	if len(names) != 4 || names[1] != "template" || names[2] != "bert" {
+		t.Fatalf("expected template between regex and bert, got %v", names)
+	}
+}
@@ -0,0 +1,111 @@
+package classifier
+
+import (
+	"fmt"
+	"log-classifier/internal/calibration"
+	"log-classifier/internal/config"
+	"log-classifier/internal/models"
+	"log-classifier/internal/taxonomy"
+)
+
+// builtinThresholds are used for stages without a configured default.
+var builtinThresholds = map[string]float64{
+	"bert": 0.2,
+	// naive Bayes probabilities are overconfident
+	"bayes": 0.9,
+	// a template label is reused without looking at the message again
+	"template": 0.7,
+}
+
+type thresholdTable struct {
+	stages map[string]float64
+	labels map[string]map[string]float64
+}
+
+func (t thresholdTable) lookup(stage, labelID string) float64 {
+	if v, ok := t.labels[stage][labelID]; ok {
+		return v
+	}
+	if v, ok := t.stages[stage]; ok {
+		return v
+	}
+	return builtinThresholds[stage]
+}
+
+var (
+	thresholds  thresholdTable
+	calibrators = map[string]calibration.Calibrator{}
+)
+
+// ConfigureThresholds sets the per-stage, per-label thresholds and loads
+// the calibration files. It must be called before the server starts
+// handling requests, after ConfigurePlugins so that plugin stages can
+// have thresholds.
+func ConfigureThresholds(stages map[string]config.StageThresholds, calibrationFiles map[string]string) error {
+	t := thresholdTable{
+		stages: make(map[string]float64),
+		labels: make(map[string]map[string]float64),
+	}
+	for stage, st := range stages {
+		if _, ok := stageByName(stage); !ok {
+			return fmt.Errorf("thresholds: unknown stage %q", stage)
+		}
+		if st.Default != nil {
+			t.stages[stage] = *st.Default
+		}
+		for labelID := range st.Labels {
+			if _, ok := taxonomy.Current().Lookup(labelID); !ok {
+				return fmt.Errorf("thresholds.%s: unknown label %q", stage, labelID)
+			}
+		}
+		t.labels[stage] = st.Labels
+	}
+
+	cals := make(map[string]calibration.Calibrator, len(calibrationFiles))
+	for stage, path := range calibrationFiles {
+		// the shadow candidate is calibrated like any other stage
+		if !knownStage(stage) {
+			return fmt.Errorf("calibration: unknown stage %q", stage)
+		}
+		c, err := calibration.Load(path)
+		if err != nil {
+			return fmt.Errorf("calibration.%s: %w", stage, err)
+		}
+		cals[stage] = c
+	}
+
+	thresholds = t
+	calibrators = cals
+	return nil
+}
+
+// calibrate replaces the stage's raw confidences with calibrated ones.
+func calibrate(stage string, r *models.ClassificationResult) {
+	c, ok := calibrators[stage]
+	if !ok {
+		return
+	}
+	r.RawConfidence = r.Confidence
+	r.Confidence = c.Calibrate(r.Confidence)
+	for i := range r.Labels {
+		r.Labels[i].Confidence = c.Calibrate(r.Labels[i].Confidence)
+	}
+	for i := range r.TopK {
+		r.TopK[i].Confidence = c.Calibrate(r.TopK[i].Confidence)
+	}
+}
+
+// acceptAbove accepts a result whose confidence reaches the threshold
+// for the stage and label.
+func acceptAbove(stage string) acceptFunc {
+	return func(r *models.ClassificationResult) (bool, string) {
+		if r.LabelID == taxonomy.Unclassified {
+			return false, "label UNCLASSIFIED"
+		}
+		t := thresholds.lookup(stage, r.LabelID)
+		if r.Confidence < t {
+			return false, fmt.Sprintf("confidence %.2f < %s threshold %.2f", r.Confidence, r.LabelID, t)
+		}
+		return true, fmt.Sprintf("confidence %.2f >= %s threshold %.2f", r.Confidence, r.LabelID, t)
+	}
+}
@@ -0,0 +1,35 @@
+package classifier
+
+import (
+	"log-classifier/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestConfigureThresholds_RejectsUnknownStages(t *testing.T) {
+	defer ConfigureThresholds(nil, nil)
+	low := 0.5
+
+	tests := []struct {
+		name        string
+		thresholds  map[string]config.StageThresholds
+		calibration map[string]string
+		wantErr     string
+	}{
+		{name: "known stage", thresholds: map[string]config.StageThresholds{"bert": {Default: &low}}},
+		{name: "misspelled threshold", thresholds: map[string]config.StageThresholds{"bret": {Default: &low}}, wantErr: `thresholds: unknown stage "bret"`},
+		{name: "candidate threshold", thresholds: map[string]config.StageThresholds{"candidate": {Default: &low}}, wantErr: `unknown stage "candidate"`},
+		{name: "misspelled calibration", calibration: map[string]string{"LLM": "llm.json"}, wantErr: `calibration: unknown stage "LLM"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ConfigureThresholds(tt.thresholds, tt.calibration)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,490 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Config holds the server settings that can be overridden from a YAML
+// (or JSON) file. Anything left out of the file keeps its default.
+type Config struct {
+	Pipeline  PipelineConfig  `yaml:"pipeline"`
+	BERT      BERTConfig      `yaml:"bert"`
+	LLM       LLMConfig       `yaml:"llm"`
+	Shadow    ShadowConfig    `yaml:"shadow"`
+	Ensemble  EnsembleConfig  `yaml:"ensemble"`
+	Taxonomy  TaxonomyConfig  `yaml:"taxonomy"`
+	Regex     RegexConfig     `yaml:"regex"`
+	Admin     AdminConfig     `yaml:"admin"`
+	Templates TemplatesConfig `yaml:"templates"`
+	Bayes     BayesConfig     `yaml:"bayes"`
+	Plugins   []PluginConfig  `yaml:"plugins"`
+	Normalize NormalizeConfig `yaml:"normalize"`
+	Redact    RedactConfig    `yaml:"redact"`
+	Secrets   SecretsConfig   `yaml:"secrets"`
+
+	// Routes are named sub-pipelines (lists of stage names) that regex
+	// route rules can send entries to.
+	Routes map[string][]string `yaml:"routes"`
+
+	// Thresholds are keyed by stage name (regex, bayes, bert, llm).
+	Thresholds map[string]StageThresholds `yaml:"thresholds"`
+	// Calibration maps a stage name to a file written by the calibrate command.
+	Calibration map[string]string `yaml:"calibration"`
+}
+
+// PipelineConfig controls the per-entry time budget. A stage is skipped
+// when the remaining budget is below its recent median latency.
+// With DegradedMode, a lower-confidence result is returned (and flagged)
+// instead of UNCLASSIFIED when a later stage is unavailable.
+type PipelineConfig struct {
+	Budget       time.Duration `yaml:"budget"`
+	DegradedMode bool          `yaml:"degraded_mode"`
+}
+
+// BERTConfig describes the BERT deployments. When CanaryURL is set,
+// CanaryPercent of the traffic (sticky by message hash) is sent to it.
+type BERTConfig struct {
+	URL           string `yaml:"url"`
+	CanaryURL     string `yaml:"canary_url"`
+	CanaryPercent int    `yaml:"canary_percent"`
+}
+
+// LLMConfig points the LLM stage at an OpenAI-compatible chat completions
+// API (OpenAI, Groq, llama.cpp, Ollama, ...), or at an ordered list of
+// Providers to fail over between. With neither BaseURL nor Providers the
+// stage calls the Python LLM service instead. The API key is read from
+// the environment variable named by APIKeyEnv. SystemPrompt is a
+// text/template executed with the taxonomy labels as .Labels.
+// ResponseFormat is "json_schema", "json_object" or "none".
+type LLMConfig struct {
+	BaseURL        string        `yaml:"base_url"`
+	Model          string        `yaml:"model"`
+	APIKeyEnv      string        `yaml:"api_key_env"`
+	Timeout        time.Duration `yaml:"timeout"`
+	Temperature    float64       `yaml:"temperature"`
+	MaxTokens      int           `yaml:"max_tokens"`
+	ResponseFormat string        `yaml:"response_format"`
+	SystemPrompt   string        `yaml:"system_prompt"`
+	Examples       []LLMExample  `yaml:"examples"`
+	Providers      []LLMProvider `yaml:"providers"`
+	Budget         LLMBudget     `yaml:"budget"`
+}
+
+// LLMProvider is one endpoint of the LLM fallback chain. Fields left
+// unset are taken from the enclosing LLMConfig.
+type LLMProvider struct {
+	Name           string        `yaml:"name"`
+	BaseURL        string        `yaml:"base_url"`
+	Model          string        `yaml:"model"`
+	APIKeyEnv      string        `yaml:"api_key_env"`
+	Timeout        time.Duration `yaml:"timeout"`
+	Temperature    *float64      `yaml:"temperature"`
+	MaxTokens      int           `yaml:"max_tokens"`
+	ResponseFormat string        `yaml:"response_format"`
+	SystemPrompt   string        `yaml:"system_prompt"`
+	Examples       []LLMExample  `yaml:"examples"`
+}
+
+// ChatProviders returns the chat completions providers in fallback order
+// with every field filled in. A BaseURL without Providers is a single
+// provider named "default"; nil means the Python LLM service is used.
+func (c LLMConfig) ChatProviders() []LLMProvider {
+	providers := c.Providers
+	if len(providers) == 0 {
+		if c.BaseURL == "" {
+			return nil
+		}
+		providers = []LLMProvider{{Name: "default", BaseURL: c.BaseURL}}
+	}
+
+	out := make([]LLMProvider, len(providers))
+	for i, p := range providers {
+		if p.Model == "" {
+			p.Model = c.Model
+		}
+		if p.APIKeyEnv == "" {
+			p.APIKeyEnv = c.APIKeyEnv
+		}
+		if p.Timeout == 0 {
+			p.Timeout = c.Timeout
+		}
+		if p.Temperature == nil {
+			t := c.Temperature
+			p.Temperature = &t
+		}
+		if p.MaxTokens == 0 {
+			p.MaxTokens = c.MaxTokens
+		}
+		if p.ResponseFormat == "" {
+			p.ResponseFormat = c.ResponseFormat
+		}
+		if p.SystemPrompt == "" {
+			p.SystemPrompt = c.SystemPrompt
+		}
+		if p.Examples == nil {
+			p.Examples = c.Examples
+		}
+		out[i] = p
+	}
+	return out
+}
+
+// LLMBudget limits the tokens the LLM stage may spend. The top-level
+// limits are global; PerSource applies to each log source separately and
+// Sources overrides it for the named ones. Zero limits are unlimited.
+// Prices are per million tokens and only feed the spend metric.
+type LLMBudget struct {
+	TokenLimits     `yaml:",inline"`
+	PerSource       TokenLimits            `yaml:"per_source"`
+	Sources         map[string]TokenLimits `yaml:"sources"`
+	PromptPrice     float64                `yaml:"prompt_price"`
+	CompletionPrice float64                `yaml:"completion_price"`
+}
+
+// TokenLimits are a token-per-minute rate and a daily allowance.
+type TokenLimits struct {
+	TokensPerMinute int `yaml:"tokens_per_minute"`
+	TokensPerDay    int `yaml:"tokens_per_day"`
+}
+
+func (l TokenLimits) validate(name string) error {
+	if l.TokensPerMinute < 0 || l.TokensPerDay < 0 {
+		return fmt.Errorf("%s token limits must not be negative", name)
+	}
+	return nil
+}
+
+// LLMExample is a few-shot example included in every prompt.
+type LLMExample struct {
+	Message string `yaml:"message"`
+	Label   string `yaml:"label"`
+}
+
+// ShadowConfig describes an alternate pipeline that is run asynchronously
+// on a sample of live traffic and compared against the primary result.
+// Pipeline lists stage names ("regex", "bert", "llm", "candidate"); the
+// "candidate" stage calls CandidateURL using the BERT service protocol.
+type ShadowConfig struct {
+	SamplePercent       int      `yaml:"sample_percent"`
+	Pipeline            []string `yaml:"pipeline"`
+	CandidateURL        string   `yaml:"candidate_url"`
+	ConfidenceTolerance float64  `yaml:"confidence_tolerance"`
+	LogSize             int      `yaml:"log_size"`
+	MaxInFlight         int      `yaml:"max_in_flight"`
+}
+
+// EnsembleConfig runs several stages in parallel for the listed sources
+// and combines their votes with Strategy ("majority", "weighted" or
+// "agree"). With "agree", a split vote is escalated to EscalateTo, or
+// left unclassified when EscalateTo is empty.
+type EnsembleConfig struct {
+	Sources    []string           `yaml:"sources"`
+	Stages     []string           `yaml:"stages"`
+	Strategy   string             `yaml:"strategy"`
+	Weights    map[string]float64 `yaml:"weights"`
+	EscalateTo string             `yaml:"escalate_to"`
+}
+
+// TaxonomyConfig defines the canonical labels. Labels reported by a stage
+// that are not in the taxonomy are mapped through Remap; anything left is
+// rejected, or mapped to UNCLASSIFIED when Unknown is "unclassified".
+// An empty label list keeps the built-in taxonomy.
+type TaxonomyConfig struct {
+	Labels  []LabelConfig     `yaml:"labels"`
+	Remap   map[string]string `yaml:"remap"`
+	Unknown string            `yaml:"unknown"`
+}
+
+type LabelConfig struct {
+	ID          string `yaml:"id"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Severity    string `yaml:"severity"`
+	Parent      string `yaml:"parent"`
+}
+
+// RegexConfig points at an external rule file. The built-in rules are
+// used when RulesFile is empty.
+type RegexConfig struct {
+	RulesFile string `yaml:"rules_file"`
+}
+
+// AdminConfig enables the admin API. Tokens maps a user name to its
+// bearer token; the name is recorded in the rule audit log. Rule changes
+// are appended to AuditLog, and the latest snapshot in it is loaded at
+// startup in place of regex.rules_file.
+type AdminConfig struct {
+	Tokens   map[string]string `yaml:"tokens"`
+	AuditLog string            `yaml:"audit_log"`
+}
+
+// TemplatesConfig enables the template stage, which mines log templates
+// online (Drain) and reuses the label decided for a template instead of
+// calling the remote stages again. Zero values keep the miner defaults.
+type TemplatesConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	Depth        int     `yaml:"depth"`
+	Similarity   float64 `yaml:"similarity"`
+	MaxChildren  int     `yaml:"max_children"`
+	MaxTemplates int     `yaml:"max_templates"`
+}
+
+// BayesConfig enables the naive Bayes stage between regex and BERT with a
+// model written by the train command. TopK is the number of most likely
+// labels returned with their probabilities.
+type BayesConfig struct {
+	ModelFile string `yaml:"model_file"`
+	TopK      int    `yaml:"top_k"`
+}
+
+// PluginConfig runs a classifier as a subprocess speaking newline-delimited
+// JSON over stdin and stdout. Name is also its stage name; the stage runs
+// in the default pipeline before the Before stage ("bert" or "llm").
+// Timeout bounds each request.
+type PluginConfig struct {
+	Name    string            `yaml:"name"`
+	Command []string          `yaml:"command"`
+	Env     map[string]string `yaml:"env"`
+	Timeout time.Duration     `yaml:"timeout"`
+	Before  string            `yaml:"before"`
+}
+
+// NormalizeConfig masks variable tokens (IPs, UUIDs, numbers, ...) in
+// messages before the stages run. Rules replaces the built-in masking
+// rules and is applied in order. Inputs chooses "raw" or "normalized"
+// input per stage; stages not listed get normalized input, except regex.
+type NormalizeConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Rules   []NormalizeRule   `yaml:"rules"`
+	Inputs  map[string]string `yaml:"inputs"`
+}
+
+type NormalizeRule struct {
+	Name        string `yaml:"name"`
+	Pattern     string `yaml:"pattern"`
+	Placeholder string `yaml:"placeholder"`
+}
+
+// RedactConfig replaces sensitive spans (credentials, tokens, card
+// numbers, emails, ...) with typed placeholders in the input of the listed
+// stages. Detectors selects built-in detectors by name (all when empty);
+// Custom adds regex detectors after them.
+type RedactConfig struct {
+	Stages    []string         `yaml:"stages"`
+	Detectors []string         `yaml:"detectors"`
+	Custom    []RedactDetector `yaml:"custom"`
+}
+
+type RedactDetector struct {
+	Name        string `yaml:"name"`
+	Pattern     string `yaml:"pattern"`
+	Placeholder string `yaml:"placeholder"`
+}
+
+// SecretsConfig enables the secret stage, which runs before regex and
+// labels entries that leak credentials as SECRET_LEAK. Custom adds regex
+// detectors to the built-in ones.
+type SecretsConfig struct {
+	Enabled bool             `yaml:"enabled"`
+	Custom  []RedactDetector `yaml:"custom"`
+}
+
+// StageThresholds is the minimum (calibrated) confidence a stage needs for
+// the pipeline to stop at its result. Labels overrides Default per label.
+// Leaving Default out keeps the stage's built-in threshold.
+type StageThresholds struct {
+	Default *float64           `yaml:"default"`
+	Labels  map[string]float64 `yaml:"labels"`
+}
+
+func Default() *Config {
+	return &Config{
+		Pipeline: PipelineConfig{
+			Budget:       6 * time.Second,
+			DegradedMode: true,
+		},
+		BERT: BERTConfig{
+			URL: "http://127.0.0.1:5000/classify",
+		},
+		LLM: LLMConfig{
+			Timeout:        2 * time.Second,
+			MaxTokens:      64,
+			ResponseFormat: "json_schema",
+		},
+		Shadow: ShadowConfig{
+			ConfidenceTolerance: 0.1,
+			LogSize:             1000,
+			MaxInFlight:         8,
+		},
+		Ensemble: EnsembleConfig{
+			Strategy: "majority",
+		},
+		Taxonomy: TaxonomyConfig{
+			Unknown: "reject",
+		},
+		Redact: RedactConfig{
+			Stages: []string{"bert", "llm", "candidate"},
+		},
+		Bayes: BayesConfig{
+			TopK: 3,
+		},
+	}
+}
+
+// builtinStages are the stage names plugins cannot use.
+var builtinStages = []string{"secret", "regex", "template", "bayes", "bert", "llm", "candidate"}
+
+// Load reads the config file at path on top of the defaults.
+// An empty path returns the defaults.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Pipeline.Budget <= 0 {
+		return fmt.Errorf("pipeline.budget must be positive, got %s", c.Pipeline.Budget)
+	}
+	if c.BERT.URL == "" {
+		return fmt.Errorf("bert.url must not be empty")
+	}
+	if c.BERT.CanaryPercent < 0 || c.BERT.CanaryPercent > 100 {
+		return fmt.Errorf("bert.canary_percent must be between 0 and 100, got %d", c.BERT.CanaryPercent)
+	}
+	if c.BERT.CanaryPercent > 0 && c.BERT.CanaryURL == "" {
+		return fmt.Errorf("bert.canary_percent is set but bert.canary_url is empty")
+	}
+	if c.LLM.Timeout <= 0 {
+		return fmt.Errorf("llm.timeout must be positive, got %s", c.LLM.Timeout)
+	}
+	if c.LLM.BaseURL != "" && c.LLM.Model == "" {
+		return fmt.Errorf("llm.base_url is set but llm.model is empty")
+	}
+	switch c.LLM.ResponseFormat {
+	case "json_schema", "json_object", "none":
+	default:
+		return fmt.Errorf("llm.response_format must be json_schema, json_object or none, got %q", c.LLM.ResponseFormat)
+	}
+	if c.LLM.BaseURL != "" && len(c.LLM.Providers) > 0 {
+		return fmt.Errorf("llm.base_url and llm.providers are mutually exclusive")
+	}
+	names := make(map[string]bool)
+	for i, p := range c.LLM.ChatProviders() {
+		switch {
+		case p.Name == "":
+			return fmt.Errorf("llm.providers[%d] has no name", i)
+		case names[p.Name]:
+			return fmt.Errorf("llm.providers: duplicate name %q", p.Name)
+		case p.BaseURL == "":
+			return fmt.Errorf("llm provider %s: base_url is empty", p.Name)
+		case p.Model == "":
+			return fmt.Errorf("llm provider %s: model is empty", p.Name)
+		case p.Timeout <= 0:
+			return fmt.Errorf("llm provider %s: timeout must be positive, got %s", p.Name, p.Timeout)
+		}
+		switch p.ResponseFormat {
+		case "json_schema", "json_object", "none":
+		default:
+			return fmt.Errorf("llm provider %s: response_format must be json_schema, json_object or none, got %q", p.Name, p.ResponseFormat)
+		}
+		names[p.Name] = true
+	}
+	if err := c.LLM.Budget.validate("llm.budget"); err != nil {
+		return err
+	}
+	if err := c.LLM.Budget.PerSource.validate("llm.budget.per_source"); err != nil {
+		return err
+	}
+	for src, l := range c.LLM.Budget.Sources {
+		if err := l.validate("llm.budget.sources." + src); err != nil {
+			return err
+		}
+	}
+	if c.LLM.Budget.PromptPrice < 0 || c.LLM.Budget.CompletionPrice < 0 {
+		return fmt.Errorf("llm.budget prices must not be negative")
+	}
+	if c.Shadow.SamplePercent < 0 || c.Shadow.SamplePercent > 100 {
+		return fmt.Errorf("shadow.sample_percent must be between 0 and 100, got %d", c.Shadow.SamplePercent)
+	}
+	if c.Shadow.SamplePercent > 0 && len(c.Shadow.Pipeline) == 0 {
+		return fmt.Errorf("shadow.sample_percent is set but shadow.pipeline is empty")
+	}
+	if c.Shadow.LogSize < 1 {
+		return fmt.Errorf("shadow.log_size must be positive, got %d", c.Shadow.LogSize)
+	}
+	if c.Shadow.MaxInFlight < 1 {
+		return fmt.Errorf("shadow.max_in_flight must be positive, got %d", c.Shadow.MaxInFlight)
+	}
+	if c.Taxonomy.Unknown != "reject" && c.Taxonomy.Unknown != "unclassified" {
+		return fmt.Errorf("taxonomy.unknown must be reject or unclassified, got %q", c.Taxonomy.Unknown)
+	}
+	plugins := make(map[string]bool)
+	for i, p := range c.Plugins {
+		switch {
+		case p.Name == "":
+			return fmt.Errorf("plugins[%d] has no name", i)
+		case plugins[p.Name]:
+			return fmt.Errorf("plugins: duplicate name %q", p.Name)
+		case slices.Contains(builtinStages, p.Name):
+			return fmt.Errorf("plugin %s: name is taken by a built-in stage", p.Name)
+		case len(p.Command) == 0:
+			return fmt.Errorf("plugin %s: command is empty", p.Name)
+		case p.Timeout < 0:
+			return fmt.Errorf("plugin %s: timeout must not be negative, got %s", p.Name, p.Timeout)
+		case p.Before != "" && p.Before != "bert" && p.Before != "llm":
+			return fmt.Errorf("plugin %s: before must be bert or llm, got %q", p.Name, p.Before)
+		}
+		plugins[p.Name] = true
+	}
+	for user, token := range c.Admin.Tokens {
+		if strings.TrimSpace(token) == "" {
+			return fmt.Errorf("admin.tokens.%s is empty", user)
+		}
+	}
+	if c.Bayes.TopK < 1 {
+		return fmt.Errorf("bayes.top_k must be positive, got %d", c.Bayes.TopK)
+	}
+	if c.Templates.Similarity < 0 || c.Templates.Similarity > 1 {
+		return fmt.Errorf("templates.similarity must be between 0 and 1, got %v", c.Templates.Similarity)
+	}
+	if c.Templates.Depth != 0 && c.Templates.Depth < 3 {
+		return fmt.Errorf("templates.depth must be at least 3, got %d", c.Templates.Depth)
+	}
+	for stage, input := range c.Normalize.Inputs {
+		if input != "raw" && input != "normalized" {
+			return fmt.Errorf("normalize.inputs.%s must be raw or normalized, got %q", stage, input)
+		}
+	}
+	if len(c.Ensemble.Sources) > 0 {
+		if len(c.Ensemble.Stages) < 2 {
+			return fmt.Errorf("ensemble.stages needs at least two stages")
+		}
+		switch c.Ensemble.Strategy {
+		case "majority", "weighted", "agree":
+		default:
+			return fmt.Errorf("ensemble.strategy must be majority, weighted or agree, got %q", c.Ensemble.Strategy)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-classifier/internal/taxonomy"
+	"strings"
+)
+
+var ErrInvalidAnswer = errors.New("invalid LLM answer")
+
+// ValidationError lists what is wrong with an answer, in words the model
+// can act on when it is asked again.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidAnswer, strings.Join(e.Problems, "; "))
+}
+
+func (e *ValidationError) Unwrap() error { return ErrInvalidAnswer }
+
+// Answer is a validated classification from the model.
+type Answer struct {
+	LabelID    string
+	Confidence float64
+
+	// Extracted is set when the JSON object had to be cut out of
+	// surrounding text; CoercedFrom holds the model's label when it was
+	// fuzzily matched onto LabelID.
+	Extracted   bool
+	CoercedFrom string
+}
+
+// Repaired reports whether the answer needed extraction or coercion.
+func (a Answer) Repaired() bool {
+	return a.Extracted || a.CoercedFrom != ""
+}
+
+// Parser extracts and validates answers against a label set.
+type Parser struct {
+	labels  *labelMatcher
+	ids     []string
+	lenient bool
+}
+
+// NewParser returns a parser for the given labels. The answer must have
+// exactly the fields label_id and confidence.
+func NewParser(labels []taxonomy.Label) *Parser {
+	p := &Parser{labels: newLabelMatcher(labels)}
+	for _, l := range labels {
+		p.ids = append(p.ids, l.ID)
+	}
+	return p
+}
+
+// NewLenientParser is like NewParser but ignores unknown fields, for
+// services that reply with more than the answer.
+func NewLenientParser(labels []taxonomy.Label) *Parser {
+	p := NewParser(labels)
+	p.lenient = true
+	return p
+}
+
+type rawAnswer struct {
+	LabelID    *string  `json:"label_id"`
+	Confidence *float64 `json:"confidence"`
+}
+
+// Parse finds the JSON object in content, checks it against the answer
+// schema and maps its label onto the label set. Errors wrap
+// ErrInvalidAnswer.
+func (p *Parser) Parse(content string) (Answer, error) {
+	var a Answer
+	obj, extracted := extractObject(content)
+	if obj == "" {
+		return a, &ValidationError{Problems: []string{"the reply contains no JSON object"}}
+	}
+	a.Extracted = extracted
+
+	dec := json.NewDecoder(strings.NewReader(obj))
+	if !p.lenient {
+		dec.DisallowUnknownFields()
+	}
+	var raw rawAnswer
+	if err := dec.Decode(&raw); err != nil {
+		return a, &ValidationError{Problems: []string{"the JSON object does not match the schema: " + err.Error()}}
+	}
+
+	var problems []string
+	switch {
+	case raw.LabelID == nil:
+		problems = append(problems, "label_id is missing")
+	default:
+		id, ok := p.labels.match(*raw.LabelID)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("label_id %q is not one of %s", *raw.LabelID, strings.Join(p.ids, ", ")))
+		}
+		a.LabelID = id
+		if ok && id != *raw.LabelID {
+			a.CoercedFrom = *raw.LabelID
+		}
+	}
+	switch {
+	case raw.Confidence == nil:
+		problems = append(problems, "confidence is missing")
+	case *raw.Confidence < 0 || *raw.Confidence > 1:
+		problems = append(problems, fmt.Sprintf("confidence %v is not between 0 and 1", *raw.Confidence))
+	default:
+		a.Confidence = *raw.Confidence
+	}
+	if len(problems) > 0 {
+		return Answer{}, &ValidationError{Problems: problems}
+	}
+	return a, nil
+}
+
+// extractObject returns the first complete JSON object in s, and whether
+// anything around it had to be dropped (prose, code fences, ...).
+func extractObject(s string) (string, bool) {
+	trimmed := strings.TrimSpace(s)
+	for start := strings.IndexByte(trimmed, '{'); start >= 0; {
+		if end := objectEnd(trimmed[start:]); end > 0 {
+			obj := trimmed[start : start+end]
+			if json.Valid([]byte(obj)) {
+				return obj, len(obj) != len(trimmed)
+			}
+		}
+		next := strings.IndexByte(trimmed[start+1:], '{')
+		if next < 0 {
+			break
+		}
+		start += 1 + next
+	}
+	return "", false
+}
+
+// objectEnd returns the length of the balanced {...} at the start of s,
+// or 0 if it is not closed.
+func objectEnd(s string) int {
+	depth := 0
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// labelMatcher maps near-miss labels ("Database Error", "db-error",
+// "DB_EROR") onto label IDs.
+type labelMatcher struct {
+	ids   map[string]bool
+	norm  map[string]string // normalized ID or name -> ID
+	order []string          // normalized IDs, for edit distance
+}
+
+func newLabelMatcher(labels []taxonomy.Label) *labelMatcher {
+	m := &labelMatcher{ids: make(map[string]bool), norm: make(map[string]string)}
+	for _, l := range labels {
+		m.ids[l.ID] = true
+		n := normalizeLabel(l.ID)
+		m.norm[n] = l.ID
+		m.order = append(m.order, n)
+		if name := normalizeLabel(l.Name); name != "" {
+			if _, taken := m.norm[name]; !taken {
+				m.norm[name] = l.ID
+			}
+		}
+	}
+	return m
+}
+
+// match tries the label as is, then normalized (case and separators),
+// then the closest ID within a small edit distance. An ambiguous closest
+// match is no match.
+func (m *labelMatcher) match(s string) (string, bool) {
+	if m.ids[s] {
+		return s, true
+	}
+	n := normalizeLabel(s)
+	if id, ok := m.norm[n]; ok {
+		return id, true
+	}
+
+	best, bestDist, tie := "", -1, false
+	for _, cand := range m.order {
+		d := editDistance(n, cand)
+		switch {
+		case bestDist < 0 || d < bestDist:
+			best, bestDist, tie = cand, d, false
+		case d == bestDist:
+			tie = true
+		}
+	}
+	if best == "" || tie || bestDist > maxLabelDistance(best) {
+		return "", false
+	}
+	return m.norm[best], true
+}
+
+// maxLabelDistance allows one edit per four characters, at most two.
+func maxLabelDistance(label string) int {
+	return min(2, len(label)/4)
+}
+
+// normalizeLabel upper-cases s and turns runs of anything but letters and
+// digits into single underscores.
+func normalizeLabel(s string) string {
+	var b bytes.Buffer
+	sep := false
+	for _, r := range strings.ToUpper(strings.TrimSpace(s)) {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			if sep && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			sep = false
+		} else {
+			sep = true
+		}
+	}
+	return b.String()
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
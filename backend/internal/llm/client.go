@@ -0,0 +1,130 @@
+// Package llm talks to OpenAI-compatible chat completions APIs (OpenAI,
+// Groq, a local llama.cpp or Ollama server, ...) and builds the
+// classification prompts sent to them.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Message is one chat message.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ResponseFormat asks the server for structured output. Type is
+// "json_schema" (with JSONSchema set) or "json_object".
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
+}
+
+type ChatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	Temperature    float64         `json:"temperature"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// EstimateTokens guesses the tokens a request will use before it is sent:
+// about four characters per token plus a few per message for the chat
+// framing, and MaxTokens for the reply.
+func EstimateTokens(req ChatRequest) int {
+	n := req.MaxTokens
+	for _, m := range req.Messages {
+		n += 4 + (len(m.Content)+3)/4
+	}
+	return n
+}
+
+type Choice struct {
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+type ChatResponse struct {
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+	Usage   Usage    `json:"usage"`
+}
+
+// Client calls the chat completions endpoint under BaseURL, e.g.
+// "https://api.groq.com/openai" or "http://127.0.0.1:11434".
+type Client struct {
+	BaseURL string
+	APIKey  string // sent as a bearer token when set
+	HTTP    *http.Client
+}
+
+// Complete sends req and returns the server's response. A response
+// without choices is an error.
+func (c *Client) Complete(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
+	}
+
+	url := strings.TrimSuffix(c.BaseURL, "/") + "/v1/chat/completions"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call chat completions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+
+	var out ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode chat response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("chat response has no choices")
+	}
+	return &out, nil
+}
+
+// StatusError is a non-200 response from the server.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("chat completions returned status %d: %s", e.StatusCode, e.Body)
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"log-classifier/internal/taxonomy"
+)
+
+var testLabels = []taxonomy.Label{
+	{ID: "DB_ERROR", Name: "Database Error", Description: "Database failures"},
+	{ID: "INFO", Name: "Informational Log"},
+}
+
+func TestPrompt_MessagesAndSchema(t *testing.T) {
+	p, err := NewPrompt("", testLabels, []Example{{Message: "deadlock detected", LabelID: "DB_ERROR"}})
+	if err != nil {
+		t.Fatalf("prompt: %v", err)
+	}
+
+	msgs := p.Messages("disk ok")
+	if len(msgs) != 4 || msgs[0].Role != "system" || msgs[1].Content != "deadlock detected" || msgs[3].Content != "disk ok" {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+	if !strings.Contains(msgs[0].Content, "- DB_ERROR: Database Error (Database failures)") {
+		t.Fatalf("system prompt does not list the labels:\n%s", msgs[0].Content)
+	}
+	if _, err := p.Parse(msgs[2].Content); err != nil {
+		t.Fatalf("few-shot answer does not parse: %v", err)
+	}
+
+	enum := p.Schema()["properties"].(map[string]any)["label_id"].(map[string]any)["enum"].([]string)
+	if len(enum) != 2 || enum[0] != "DB_ERROR" {
+		t.Fatalf("unexpected label enum: %v", enum)
+	}
+}
+
+func TestNewPrompt_RejectsUnknownExampleLabel(t *testing.T) {
+	if _, err := NewPrompt("", testLabels, []Example{{Message: "x", LabelID: "NOPE"}}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := NewPrompt("{{.Nope}}", testLabels, nil); err == nil {
+		t.Fatalf("expected template error")
+	}
+}
+
+func TestParser_ValidatesStrictly(t *testing.T) {
+	p := NewParser(testLabels)
+
+	if a, err := p.Parse(`{"label_id": "INFO", "confidence": 0.7}`); err != nil || a.LabelID != "INFO" || a.Confidence != 0.7 || a.Repaired() {
+		t.Fatalf("expected valid answer, got %+v, %v", a, err)
+	}
+	for _, bad := range []string{
+		`no JSON here`,
+		`{"label_id": "INFO", "confidence": 0.7, "reason": "x"}`,
+		`{"label_id": "NOPE", "confidence": 0.7}`,
+		`{"label_id": "INFO"}`,
+		`{"label_id": "INFO", "confidence": "high"}`,
+		`{"label_id": "INFO", "confidence": 7}`,
+	} {
+		if _, err := p.Parse(bad); !errors.Is(err, ErrInvalidAnswer) {
+			t.Errorf("%s: expected ErrInvalidAnswer, got %v", bad, err)
+		}
+	}
+
+	lenient := NewLenientParser(testLabels)
+	if _, err := lenient.Parse(`{"label_id": "INFO", "label": "Informational Log", "confidence": 0.7}`); err != nil {
+		t.Fatalf("lenient parser should ignore extra fields: %v", err)
+	}
+}
+
+func TestParser_ExtractsJSONFromText(t *testing.T) {
+	p := NewParser(testLabels)
+	for _, content := range []string{
+		"Sure! Here is the classification:\n{\"label_id\": \"DB_ERROR\", \"confidence\": 0.9}\nLet me know.",
+		"```json\n{\"label_id\": \"DB_ERROR\", \"confidence\": 0.9}\n```",
+		`Using {braces} in prose, then {"label_id": "DB_ERROR", "confidence": 0.9}`,
+		`{"label_id": "DB_ERROR", "confidence": 0.9} trailing`,
+	} {
+		a, err := p.Parse(content)
+		if err != nil || a.LabelID != "DB_ERROR" || !a.Extracted {
+			t.Errorf("%q: expected extracted DB_ERROR, got %+v, %v", content, a, err)
+		}
+	}
+}
+
+func TestParser_CoercesNearMissLabels(t *testing.T) {
+	p := NewParser(append(testLabels, taxonomy.Label{ID: "AUTH_ERROR", Name: "Authentication Error"}))
+	cases := map[string]string{
+		"db_error":             "DB_ERROR",
+		"DB-ERROR":             "DB_ERROR",
+		"Database Error":       "DB_ERROR",
+		"DB_EROR":              "DB_ERROR",
+		"Informational log":    "INFO",
+		"authentication error": "AUTH_ERROR",
+	}
+	for label, want := range cases {
+		a, err := p.Parse(`{"label_id": "` + label + `", "confidence": 0.5}`)
+		if err != nil || a.LabelID != want || a.CoercedFrom != label {
+			t.Errorf("%q: expected %s, got %+v, %v", label, want, a, err)
+		}
+	}
+
+	// too far from every label
+	for _, label := range []string{"NETWORK_ERROR", "XYZ_ERROR", "WARN"} {
+		if _, err := p.Parse(`{"label_id": "` + label + `", "confidence": 0.5}`); err == nil {
+			t.Errorf("%q: expected no match", label)
+		}
+	}
+}
+
+func TestPrompt_Reask(t *testing.T) {
+	p, _ := NewPrompt("", testLabels, nil)
+	msgs := p.Messages("x")
+	_, err := p.Parse(`{"label_id": "NOPE"}`)
+
+	next := p.Reask(msgs, `{"label_id": "NOPE"}`, err)
+	if len(next) != len(msgs)+2 || len(msgs) != 2 {
+		t.Fatalf("expected the reply and a correction appended, got %d messages", len(next))
+	}
+	last := next[len(next)-1].Content
+	if !strings.Contains(last, `label_id "NOPE" is not one of DB_ERROR, INFO`) || !strings.Contains(last, "confidence is missing") {
+		t.Fatalf("correction does not explain the problems: %s", last)
+	}
+}
+
+func TestClient_Complete(t *testing.T) {
+	var got ChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer k" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"model": "m", "choices": [{"message": {"role": "assistant", "content": "{}"}, "finish_reason": "stop"}], "usage": {"total_tokens": 12}}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL + "/", APIKey: "k"}
+	resp, err := c.Complete(context.Background(), ChatRequest{Model: "m", Messages: []Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if got.Model != "m" || resp.Usage.TotalTokens != 12 || resp.Choices[0].Message.Content != "{}" {
+		t.Fatalf("unexpected exchange: %+v / %+v", got, resp)
+	}
+
+	c.APIKey = "wrong"
+	var statusErr *StatusError
+	if _, err := c.Complete(context.Background(), ChatRequest{}); !errors.As(err, &statusErr) || statusErr.StatusCode != 400 {
+		t.Fatalf("expected StatusError 400, got %v", err)
+	}
+}
+
+func TestLabelMatcher_AmbiguousIsNoMatch(t *testing.T) {
+	m := newLabelMatcher([]taxonomy.Label{{ID: "ABCD_1"}, {ID: "ABCD_2"}})
+	if id, ok := m.match("ABCD_3"); ok {
+		t.Fatalf("expected no match for a tie, got %s", id)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	req := ChatRequest{
+		MaxTokens: 64,
+		Messages:  []Message{{Role: "system", Content: strings.Repeat("x", 400)}, {Role: "user", Content: "abc"}},
+	}
+	if got := EstimateTokens(req); got != 64+4+100+4+1 {
+		t.Fatalf("EstimateTokens = %d", got)
+	}
+}
@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-classifier/internal/taxonomy"
+	"strings"
+	"text/template"
+)
+
+// DefaultSystemPrompt is the system prompt template. It is executed with
+// the label list as .Labels.
+const DefaultSystemPrompt = `You classify application log messages.
+Choose exactly one label from this list:
+{{range .Labels}}- {{.ID}}: {{.Name}}{{if .Description}} ({{.Description}}){{end}}
+{{end}}
+Reply with a JSON object {"label_id": "<one of the label IDs>", "confidence": <number between 0 and 1>} and nothing else.`
+
+// Example is a few-shot example: a log message and its correct label.
+type Example struct {
+	Message string
+	LabelID string
+}
+
+// Prompt turns log messages into chat requests for a fixed label set.
+type Prompt struct {
+	system   string
+	examples []Example
+	labels   []string
+	parser   *Parser
+}
+
+// NewPrompt renders the system prompt template for labels and checks
+// that every example uses one of them. An empty system uses
+// DefaultSystemPrompt.
+func NewPrompt(system string, labels []taxonomy.Label, examples []Example) (*Prompt, error) {
+	if system == "" {
+		system = DefaultSystemPrompt
+	}
+	tmpl, err := template.New("system").Parse(system)
+	if err != nil {
+		return nil, fmt.Errorf("system prompt: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, struct{ Labels []taxonomy.Label }{labels}); err != nil {
+		return nil, fmt.Errorf("system prompt: %w", err)
+	}
+
+	p := &Prompt{system: buf.String(), examples: examples, parser: NewParser(labels)}
+	known := make(map[string]bool, len(labels))
+	for _, l := range labels {
+		p.labels = append(p.labels, l.ID)
+		known[l.ID] = true
+	}
+	for i, ex := range examples {
+		if !known[ex.LabelID] {
+			return nil, fmt.Errorf("example %d: unknown label %q", i, ex.LabelID)
+		}
+	}
+	return p, nil
+}
+
+// Messages builds the conversation for msg: the system prompt, each
+// few-shot example as a user/assistant exchange, then msg.
+func (p *Prompt) Messages(msg string) []Message {
+	msgs := []Message{{Role: "system", Content: p.system}}
+	for _, ex := range p.examples {
+		answer, _ := json.Marshal(map[string]any{"label_id": ex.LabelID, "confidence": 1.0})
+		msgs = append(msgs,
+			Message{Role: "user", Content: ex.Message},
+			Message{Role: "assistant", Content: string(answer)},
+		)
+	}
+	return append(msgs, Message{Role: "user", Content: msg})
+}
+
+// Schema is the JSON schema of an Answer restricted to the prompt's labels.
+func (p *Prompt) Schema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"label_id":   map[string]any{"type": "string", "enum": p.labels},
+			"confidence": map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+		},
+		"required":             []string{"label_id", "confidence"},
+		"additionalProperties": false,
+	}
+}
+
+// ResponseFormat returns the structured output request for mode
+// ("json_schema", "json_object" or "none").
+func (p *Prompt) ResponseFormat(mode string) *ResponseFormat {
+	switch mode {
+	case "json_schema":
+		return &ResponseFormat{Type: "json_schema", JSONSchema: &JSONSchema{Name: "log_classification", Strict: true, Schema: p.Schema()}}
+	case "json_object":
+		return &ResponseFormat{Type: "json_object"}
+	}
+	return nil
+}
+
+// Parse validates the model's reply; see Parser.Parse.
+func (p *Prompt) Parse(content string) (Answer, error) {
+	return p.parser.Parse(content)
+}
+
+// Reask continues the conversation after an invalid reply: the reply
+// itself, then a request to fix the problems err describes.
+func (p *Prompt) Reask(msgs []Message, reply string, err error) []Message {
+	problems := err.Error()
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		problems = strings.Join(verr.Problems, "; ")
+	}
+	return append(msgs[:len(msgs):len(msgs)],
+		Message{Role: "assistant", Content: reply},
+		Message{Role: "user", Content: fmt.Sprintf(
+			"Your reply was invalid: %s. Reply again with only a JSON object {\"label_id\": one of %s, \"confidence\": a number between 0 and 1}.",
+			problems, strings.Join(p.labels, ", "))},
+	)
+}
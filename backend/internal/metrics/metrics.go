@@ -69,4 +69,195 @@ var (
 		},
 		[]string{"endpoint", "method"},
 	)
+
+	// Counter for BERT calls per deployment variant (stable/canary)
+	BERTVariantRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_bert_variant_requests_total",
+			Help: "Total number of BERT calls by variant and status",
+		},
+		[]string{"variant", "status"},
+	)
+
+	// Histogram for BERT latency per deployment variant
+	BERTVariantDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "log_classifier_bert_variant_duration_seconds",
+			Help:    "Duration of BERT calls by variant",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"variant"},
+	)
+
+	// Counter for labels returned per deployment variant
+	BERTVariantLabels = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_bert_variant_labels_total",
+			Help: "Labels returned by BERT by variant, with label IDs not in the taxonomy as unknown",
+		},
+		[]string{"variant", "label"},
+	)
+
+	// Counter for shadow comparisons (agree, disagree, dropped)
+	ShadowComparisons = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_shadow_comparisons_total",
+			Help: "Shadow pipeline comparisons by outcome",
+		},
+		[]string{"outcome"},
+	)
+
+	// Counter for shadow disagreements per compared field
+	ShadowDisagreements = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_shadow_disagreements_total",
+			Help: "Shadow pipeline disagreements by field (label, confidence, stage)",
+		},
+		[]string{"field"},
+	)
+
+	// Gauge for the cumulative shadow agreement rate
+	ShadowAgreementRate = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "log_classifier_shadow_agreement_ratio",
+			Help: "Fraction of shadow comparisons that agreed with the primary result",
+		},
+	)
+
+	// Counter for stages skipped by the pipeline
+	StagesSkipped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_stages_skipped_total",
+			Help: "Total number of pipeline stages skipped by stage and reason",
+		},
+		[]string{"stage", "reason"},
+	)
+
+	// Counter for best-effort results returned in degraded mode
+	DegradedResults = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_degraded_results_total",
+			Help: "Total number of degraded best-effort results by reason",
+		},
+		[]string{"reason"},
+	)
+
+	// Gauge for the server-wide degraded state
+	DegradedMode = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "log_classifier_degraded",
+			Help: "Whether the server is running degraded (1 = degraded, 0 = healthy)",
+		},
+	)
+
+	// Counter for stage labels checked against the taxonomy
+	LabelValidations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_label_validations_total",
+			Help: "Stage labels checked against the taxonomy by outcome (valid, remapped, rejected)",
+		},
+		[]string{"stage", "outcome"},
+	)
+
+	// Counter for regex rule actions (label, drop, tag, route, rewrite)
+	RuleActions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_rule_actions_total",
+			Help: "Total number of regex rule actions applied by action",
+		},
+		[]string{"action"},
+	)
+
+	// Counter for regex rule hits by rule ID, bounded by rules.MaxHitSeries
+	RuleHits = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_rule_hits_total",
+			Help: "Total number of regex rule matches by rule ID",
+		},
+		[]string{"rule"},
+	)
+
+	// Counter for template stage lookups (reused, unlabeled, ambiguous, learned, full)
+	TemplateLookups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_template_lookups_total",
+			Help: "Template stage lookups by outcome (reused, unlabeled, ambiguous, learned, full)",
+		},
+		[]string{"outcome"},
+	)
+
+	// Gauge for the number of learned log templates
+	Templates = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "log_classifier_templates",
+			Help: "Number of log templates learned by the template stage",
+		},
+	)
+
+	// Counter for sensitive spans redacted from stage input
+	Redactions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_redactions_total",
+			Help: "Sensitive spans redacted before a stage call, by stage and detector",
+		},
+		[]string{"stage", "detector"},
+	)
+
+	// Counter for leaked secrets found by the secret stage
+	SecretLeaks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_secret_leaks_total",
+			Help: "Secrets found in log messages by the secret stage, by type",
+		},
+		[]string{"type"},
+	)
+
+	// Counter for LLM answers by validation outcome
+	LLMAnswers = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_llm_answers_total",
+			Help: "LLM answers by validation outcome (valid, extracted, coerced, reasked, invalid)",
+		},
+		[]string{"outcome"},
+	)
+
+	LLMTokens = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_llm_tokens_total",
+			Help: "Tokens spent by the LLM stage by source and kind (prompt, completion, or estimated when the backend reports no usage)",
+		},
+		[]string{"source", "kind"},
+	)
+
+	LLMSpend = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_llm_spend_total",
+			Help: "Estimated LLM cost by source, in the currency of the configured prices",
+		},
+		[]string{"source"},
+	)
+
+	LLMBudgetExhausted = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_llm_budget_exhausted_total",
+			Help: "LLM calls skipped because a token budget was exhausted, by scope (global, source) and window (minute, day)",
+		},
+		[]string{"scope", "window"},
+	)
+
+	LLMProviderCalls = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_llm_provider_calls_total",
+			Help: "LLM provider calls by provider and outcome (answered, circuit_open, budget_exhausted, invalid, timeout, error)",
+		},
+		[]string{"provider", "outcome"},
+	)
+
+	PluginRestarts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "log_classifier_plugin_restarts_total",
+			Help: "Plugin subprocess restarts by plugin",
+		},
+		[]string{"plugin"},
+	)
 )
@@ -11,4 +11,109 @@ type ClassificationResult struct {
 	Classifier string  `json:"classifier"`
 	LogSource  string  `json:"log_source"`
 	Confidence float64 `json:"confidence"`
+
+	// OriginalMessage and NormalizedMessage are set when message
+	// normalization is enabled; stages see one or the other.
+	OriginalMessage   string `json:"original_message,omitempty"`
+	NormalizedMessage string `json:"normalized_message,omitempty"`
+
+	// RawConfidence is the stage's own score when Confidence was calibrated.
+	RawConfidence float64 `json:"raw_confidence,omitempty"`
+
+	// Labels holds every label that applies to the entry, best first.
+	// It is only returned when multi-label results are requested.
+	Labels []LabelScore `json:"labels,omitempty"`
+
+	// TopK holds the most likely labels with their probabilities when
+	// the result comes from the bayes stage.
+	TopK []LabelScore `json:"top_k,omitempty"`
+
+	// Entities are values extracted by named capture groups in a regex
+	// rule, e.g. {"user": "admin"}. SubType is rendered from a rule template.
+	Entities map[string]string `json:"entities,omitempty"`
+	SubType  string            `json:"sub_type,omitempty"`
+
+	// Tags are added by regex tag rules. Dropped is set when a drop rule
+	// suppressed the entry; such results should not be stored.
+	Tags    []string `json:"tags,omitempty"`
+	Dropped bool     `json:"dropped,omitempty"`
+
+	// Secrets locates the credentials in a SECRET_LEAK entry: the kind of
+	// secret and its byte offsets in the message. Values are never included.
+	Secrets []SecretFinding `json:"secrets,omitempty"`
+
+	// MatchedRule identifies the regex rule that produced the result.
+	MatchedRule string `json:"matched_rule,omitempty"`
+
+	// TemplateID is the mined log template the entry belongs to, when
+	// template mining is enabled.
+	TemplateID int `json:"template_id,omitempty"`
+
+	// ModelVariant is the BERT deployment (stable/canary) that answered.
+	ModelVariant string `json:"model_variant,omitempty"`
+
+	// LLMProvider is the chat completions provider that answered, when
+	// the LLM stage calls one.
+	LLMProvider string `json:"llm_provider,omitempty"`
+
+	// Votes lists the individual stage results when the entry was
+	// classified by an ensemble.
+	Votes     []StageVote `json:"votes,omitempty"`
+	Escalated bool        `json:"escalated,omitempty"`
+
+	// Skipped lists the stages the pipeline did not run and why.
+	Skipped []SkippedStage `json:"skipped,omitempty"`
+
+	// Degraded is set when a best-effort result is returned because a
+	// later stage was unavailable.
+	Degraded       bool   `json:"degraded,omitempty"`
+	DegradedReason string `json:"degraded_reason,omitempty"`
+
+	// Trace is the per-stage decision trace, only set in explain mode.
+	Trace []StageTrace `json:"trace,omitempty"`
+}
+
+// StageTrace describes how one stage took part in a classification.
+// Outcome is one of accepted, rejected, no_result, error, vote or skipped.
+// Breakers holds each provider's breaker state when the LLM stage fails
+// over between several.
+type StageTrace struct {
+	Stage      string            `json:"stage"`
+	Outcome    string            `json:"outcome"`
+	LabelID    string            `json:"label_id,omitempty"`
+	Confidence float64           `json:"confidence"`
+	LatencyMs  float64           `json:"latency_ms"`
+	Retries    int               `json:"retries"`
+	Breaker    string            `json:"breaker,omitempty"`
+	Breakers   map[string]string `json:"breakers,omitempty"`
+	Rule       string            `json:"rule,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+type SecretFinding struct {
+	Type  string `json:"type"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type SkippedStage struct {
+	Stage  string `json:"stage"`
+	Reason string `json:"reason"`
+}
+
+// LabelScore is one of several labels on a multi-label result. Path is
+// the label's position in the taxonomy, root first (e.g. ERROR, DB_ERROR).
+type LabelScore struct {
+	LabelID    string   `json:"label_id"`
+	Label      string   `json:"label"`
+	Confidence float64  `json:"confidence"`
+	Path       []string `json:"path,omitempty"`
+}
+
+type StageVote struct {
+	Stage      string  `json:"stage"`
+	LabelID    string  `json:"label_id,omitempty"`
+	Label      string  `json:"label,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Error      string  `json:"error,omitempty"`
 }
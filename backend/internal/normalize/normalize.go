@@ -0,0 +1,67 @@
+// Package normalize masks the variable parts of log messages (addresses,
+// IDs, numbers, ...) with placeholder tokens, so that messages reporting
+// the same event look the same to the classifier stages.
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Rule replaces every match of Pattern with Placeholder. The placeholder
+// may reference capture groups, e.g. "${1}<PATH>".
+type Rule struct {
+	Name        string `yaml:"name" json:"name"`
+	Pattern     string `yaml:"pattern" json:"pattern"`
+	Placeholder string `yaml:"placeholder" json:"placeholder"`
+}
+
+// Default returns the built-in masking rules. Order matters: a URL is
+// masked before the path and IP inside it, and a UUID before its numbers.
+func Default() []Rule {
+	return []Rule{
+		{Name: "url", Pattern: `\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`, Placeholder: "<URL>"},
+		{Name: "email", Pattern: `\b[\w.+-]+@[\w-]+(?:\.[\w-]+)+\b`, Placeholder: "<EMAIL>"},
+		{Name: "uuid", Pattern: `\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`, Placeholder: "<UUID>"},
+		{Name: "ipv6", Pattern: `\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b`, Placeholder: "<IP>"},
+		{Name: "ipv4", Pattern: `\b(?:\d{1,3}\.){3}\d{1,3}(?::\d{1,5})?\b`, Placeholder: "<IP>"},
+		{Name: "path", Pattern: `(^|[\s=:"'(\[])(?:/[\w.@-]+)+/?`, Placeholder: "${1}<PATH>"},
+		{Name: "duration", Pattern: `\b(?:\d+(?:\.\d+)?(?:ns|us|µs|ms|s|m|h|d))+\b`, Placeholder: "<DURATION>"},
+		{Name: "number", Pattern: `\b\d+(?:\.\d+)?\b`, Placeholder: "<NUM>"},
+		{Name: "hex", Pattern: `\b(?:0[xX][0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`, Placeholder: "<HEX>"},
+	}
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// Normalizer applies masking rules in order.
+type Normalizer struct {
+	rules []compiledRule
+}
+
+// New compiles rules. Rules without a name are named after their position.
+func New(rules []Rule) (*Normalizer, error) {
+	n := &Normalizer{}
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("normalize rule %s: invalid pattern: %w", r.Name, err)
+		}
+		n.rules = append(n.rules, compiledRule{Rule: r, re: re})
+	}
+	return n, nil
+}
+
+// Normalize returns msg with every rule applied.
+func (n *Normalizer) Normalize(msg string) string {
+	for _, r := range n.rules {
+		msg = r.re.ReplaceAllString(msg, r.Placeholder)
+	}
+	return msg
+}
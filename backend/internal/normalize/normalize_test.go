@@ -0,0 +1,42 @@
+package normalize
+
+import "testing"
+
+func TestDefault_MasksVariableTokens(t *testing.T) {
+	n, err := New(Default())
+	if err != nil {
+		t.Fatalf("built-in rules: %v", err)
+	}
+
+	cases := map[string]string{
+		"Connection to 10.0.0.12:5432 failed after 3 retries":             "Connection to <IP> failed after <NUM> retries",
+		"Request 550e8400-e29b-41d4-a716-446655440000 took 1.5s":          "Request <UUID> took <DURATION>",
+		"Mail to ops@example.com bounced":                                 "Mail to <EMAIL> bounced",
+		"Cannot open /var/log/app/server.log: permission denied":          "Cannot open <PATH>: permission denied",
+		"GET https://api.example.com/v1/users/42 returned 500":            "GET <URL> returned <NUM>",
+		"Object deadbeef0042 at 0x7ffe12 evicted after 1h30m":             "Object <HEX> at <HEX> evicted after <DURATION>",
+		"User User123 logged in.":                                         "User User123 logged in.",
+		"fe80:0000:0000:0000:0202:b3ff:fe1e:8329 unreachable (path=/tmp)": "<IP> unreachable (path=<PATH>)",
+	}
+	for in, want := range cases {
+		if got := n.Normalize(in); got != want {
+			t.Errorf("%q:\n  expected %q\n  got      %q", in, want, got)
+		}
+	}
+}
+
+func TestNew_RejectsInvalidPattern(t *testing.T) {
+	if _, err := New([]Rule{{Name: "bad", Pattern: "("}}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestNormalize_AppliesRulesInOrder(t *testing.T) {
+	n, _ := New([]Rule{
+		{Pattern: `secret=\S+`, Placeholder: "secret=<REDACTED>"},
+		{Pattern: `\d+`, Placeholder: "<N>"},
+	})
+	if got := n.Normalize("secret=abc123 id=7"); got != "secret=<REDACTED> id=<N>" {
+		t.Fatalf("unexpected result %q", got)
+	}
+}
@@ -0,0 +1,365 @@
+// Package plugin runs an external classifier as a subprocess that speaks
+// newline-delimited JSON over stdin and stdout.
+//
+// Each request is one line on the plugin's stdin:
+//
+//	{"id": 17, "source": "app", "log_message": "Connection refused"}
+//
+// and the plugin answers with one line on stdout carrying the same id:
+//
+//	{"id": 17, "label_id": "DB_ERROR", "confidence": 0.8}
+//
+// An empty label_id means the plugin has no opinion, and an "error" field
+// reports a failure. Requests are multiplexed, so a plugin may answer them
+// in any order and work on several at once. Lines that are not valid JSON
+// are logged and ignored; stderr is logged. A plugin that exits is
+// restarted with exponential backoff, and it should exit when its stdin
+// is closed.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrUnavailable is returned while the plugin is not running.
+	ErrUnavailable = errors.New("plugin is not running")
+	// ErrExited is returned for requests in flight when the plugin exits.
+	ErrExited = errors.New("plugin exited")
+)
+
+// Request is sent to the plugin.
+type Request struct {
+	ID         uint64 `json:"id"`
+	Source     string `json:"source"`
+	LogMessage string `json:"log_message"`
+}
+
+// Response is read from the plugin.
+type Response struct {
+	ID         uint64  `json:"id"`
+	LabelID    string  `json:"label_id"`
+	Confidence float64 `json:"confidence"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Config describes the subprocess. Zero backoffs take the defaults.
+type Config struct {
+	Name    string
+	Command []string
+	// Env is added to the server's environment, as KEY=VALUE.
+	Env []string
+
+	MinBackoff time.Duration // first restart delay, default 100ms
+	MaxBackoff time.Duration // default 30s
+
+	// OnRestart is called before each restart, e.g. to count it.
+	OnRestart func()
+}
+
+// Plugin is a supervised plugin process. It is safe for concurrent use.
+type Plugin struct {
+	cfg    Config
+	nextID atomic.Uint64
+
+	mu   sync.Mutex
+	proc *process // nil while the plugin is down
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Start launches the plugin and keeps it running until Close. It fails
+// if the first launch does.
+func Start(cfg Config) (*Plugin, error) {
+	if len(cfg.Command) == 0 {
+		return nil, fmt.Errorf("plugin %s: no command", cfg.Name)
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 30 * time.Second
+	}
+
+	p := &Plugin{cfg: cfg, stop: make(chan struct{}), done: make(chan struct{})}
+	proc, err := p.launch()
+	if err != nil {
+		return nil, err
+	}
+	p.proc = proc
+	go p.supervise(proc)
+	return p, nil
+}
+
+// Classify sends one request and waits for its answer or ctx.
+func (p *Plugin) Classify(ctx context.Context, source, msg string) (Response, error) {
+	p.mu.Lock()
+	proc := p.proc
+	p.mu.Unlock()
+	if proc == nil {
+		return Response{}, fmt.Errorf("plugin %s: %w", p.cfg.Name, ErrUnavailable)
+	}
+
+	id := p.nextID.Add(1)
+	ch, err := proc.register(id)
+	if err != nil {
+		return Response{}, fmt.Errorf("plugin %s: %w", p.cfg.Name, err)
+	}
+	defer proc.unregister(id)
+
+	line, err := json.Marshal(Request{ID: id, Source: source, LogMessage: msg})
+	if err != nil {
+		return Response{}, err
+	}
+	select {
+	case proc.requests <- append(line, '\n'):
+	case <-proc.exited:
+		return Response{}, fmt.Errorf("plugin %s: %w", p.cfg.Name, ErrExited)
+	case <-ctx.Done():
+		return Response{}, ctx.Err()
+	}
+
+	select {
+	case a := <-ch:
+		if a.err != nil {
+			return Response{}, fmt.Errorf("plugin %s: %w", p.cfg.Name, a.err)
+		}
+		if a.resp.Error != "" {
+			return Response{}, fmt.Errorf("plugin %s: %s", p.cfg.Name, a.resp.Error)
+		}
+		return a.resp, nil
+	case <-ctx.Done():
+		return Response{}, ctx.Err()
+	}
+}
+
+// Close stops the plugin and waits for the supervisor to finish.
+func (p *Plugin) Close() {
+	select {
+	case <-p.stop:
+		return
+	default:
+	}
+	close(p.stop)
+
+	p.mu.Lock()
+	proc := p.proc
+	p.mu.Unlock()
+	if proc != nil {
+		proc.kill()
+	}
+	<-p.done
+}
+
+// supervise restarts the plugin whenever it exits. The delay doubles
+// after every restart that did not answer a request, up to MaxBackoff.
+func (p *Plugin) supervise(proc *process) {
+	defer close(p.done)
+	backoff := p.cfg.MinBackoff
+	for {
+		if proc != nil {
+			err := proc.wait()
+			p.mu.Lock()
+			p.proc = nil
+			p.mu.Unlock()
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+			if proc.answered.Load() {
+				backoff = p.cfg.MinBackoff
+			}
+			log.Printf("plugin %s exited (%v), restarting in %s", p.cfg.Name, err, backoff)
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, p.cfg.MaxBackoff)
+
+		if p.cfg.OnRestart != nil {
+			p.cfg.OnRestart()
+		}
+		var err error
+		if proc, err = p.launch(); err != nil {
+			log.Printf("plugin %s: %v", p.cfg.Name, err)
+			continue
+		}
+
+		p.mu.Lock()
+		select {
+		case <-p.stop:
+			// closed while launching
+			p.mu.Unlock()
+			proc.kill()
+			proc.wait()
+			return
+		default:
+		}
+		p.proc = proc
+		p.mu.Unlock()
+	}
+}
+
+// answer is a response or the reason none will come.
+type answer struct {
+	resp Response
+	err  error
+}
+
+// process is one run of the plugin.
+type process struct {
+	name     string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	requests chan []byte
+	stderr   chan struct{} // closed once stderr is drained
+	exited   chan struct{}
+	err      error // set before exited is closed
+	answered atomic.Bool
+
+	mu      sync.Mutex
+	pending map[uint64]chan answer
+	closed  bool
+}
+
+func (p *Plugin) launch() (*process, error) {
+	cmd := exec.Command(p.cfg.Command[0], p.cfg.Command[1:]...)
+	cmd.Env = append(os.Environ(), p.cfg.Env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.cfg.Name, err)
+	}
+
+	proc := &process{
+		name:     p.cfg.Name,
+		cmd:      cmd,
+		stdin:    stdin,
+		requests: make(chan []byte),
+		stderr:   make(chan struct{}),
+		exited:   make(chan struct{}),
+		pending:  make(map[uint64]chan answer),
+	}
+	go proc.write()
+	go proc.logStderr(stderr)
+	go proc.read(stdout)
+	return proc, nil
+}
+
+// write sends requests to stdin one line at a time.
+func (proc *process) write() {
+	for {
+		select {
+		case line := <-proc.requests:
+			if _, err := proc.stdin.Write(line); err != nil {
+				proc.kill()
+				return
+			}
+		case <-proc.exited:
+			return
+		}
+	}
+}
+
+// read dispatches the responses until stdout closes, then reaps the
+// process and fails the requests still in flight.
+func (proc *process) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			log.Printf("plugin %s: ignoring invalid response %q: %v", proc.name, scanner.Text(), err)
+			continue
+		}
+		proc.mu.Lock()
+		ch, ok := proc.pending[resp.ID]
+		delete(proc.pending, resp.ID)
+		proc.mu.Unlock()
+		if ok {
+			proc.answered.Store(true)
+			ch <- answer{resp: resp}
+		}
+	}
+
+	// a plugin that stops reading its stdin would block the writer
+	proc.stdin.Close()
+	<-proc.stderr
+	err := proc.cmd.Wait()
+	if err == nil {
+		err = scanner.Err()
+	}
+
+	proc.mu.Lock()
+	proc.closed = true
+	for id, ch := range proc.pending {
+		ch <- answer{err: ErrExited}
+		delete(proc.pending, id)
+	}
+	proc.mu.Unlock()
+
+	proc.err = err
+	close(proc.exited)
+}
+
+func (proc *process) logStderr(stderr io.Reader) {
+	defer close(proc.stderr)
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		log.Printf("plugin %s: %s", proc.name, scanner.Text())
+	}
+}
+
+func (proc *process) register(id uint64) (chan answer, error) {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	if proc.closed {
+		return nil, ErrExited
+	}
+	ch := make(chan answer, 1)
+	proc.pending[id] = ch
+	return ch, nil
+}
+
+func (proc *process) unregister(id uint64) {
+	proc.mu.Lock()
+	delete(proc.pending, id)
+	proc.mu.Unlock()
+}
+
+// wait returns once the process has exited.
+func (proc *process) wait() error {
+	<-proc.exited
+	return proc.err
+}
+
+func (proc *process) kill() {
+	proc.stdin.Close()
+	proc.cmd.Process.Kill()
+}
@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary act as a plugin when PLUGIN_HELPER names
+// a behavior, so the tests need no other executable.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("PLUGIN_HELPER"); mode != "" {
+		runHelper(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runHelper answers each request with the first word of the message as
+// the label. "reverse" answers pairs of requests in reverse order,
+// "crash" exits on a message of "crash", and "noisy" writes junk first.
+func runHelper(mode string) {
+	in := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	var held []Request
+	for in.Scan() {
+		var req Request
+		json.Unmarshal(in.Bytes(), &req)
+		switch {
+		case mode == "crash" && req.LogMessage == "crash":
+			os.Exit(3)
+		case mode == "noisy":
+			fmt.Println("starting up...")
+			fmt.Fprintln(os.Stderr, "debug: got", req.ID)
+		case mode == "reverse":
+			held = append(held, req)
+			if len(held) < 2 {
+				continue
+			}
+			for i := len(held) - 1; i >= 0; i-- {
+				out.Encode(helperAnswer(held[i]))
+			}
+			held = held[:0]
+			continue
+		}
+		out.Encode(helperAnswer(req))
+	}
+}
+
+func helperAnswer(req Request) Response {
+	if req.LogMessage == "fail" {
+		return Response{ID: req.ID, Error: "cannot classify"}
+	}
+	if req.LogMessage == "" {
+		return Response{ID: req.ID}
+	}
+	return Response{ID: req.ID, LabelID: strings.Fields(req.LogMessage)[0], Confidence: 0.9}
+}
+
+func startHelper(t *testing.T, mode string, restarts *atomic.Int32) *Plugin {
+	t.Helper()
+	p, err := Start(Config{
+		Name:       "helper",
+		Command:    []string{os.Args[0], "-test.run=^$"},
+		Env:        []string{"PLUGIN_HELPER=" + mode},
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+		OnRestart: func() {
+			if restarts != nil {
+				restarts.Add(1)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestPlugin_Classify(t *testing.T) {
+	p := startHelper(t, "noisy", nil)
+
+	resp, err := p.Classify(t.Context(), "app", "DB_ERROR connection refused")
+	if err != nil || resp.LabelID != "DB_ERROR" || resp.Confidence != 0.9 {
+		t.Fatalf("unexpected answer %+v, %v", resp, err)
+	}
+	if resp, err := p.Classify(t.Context(), "app", ""); err != nil || resp.LabelID != "" {
+		t.Fatalf("expected no opinion, got %+v, %v", resp, err)
+	}
+	if _, err := p.Classify(t.Context(), "app", "fail"); err == nil || !strings.Contains(err.Error(), "cannot classify") {
+		t.Fatalf("expected the plugin's error, got %v", err)
+	}
+}
+
+func TestPlugin_MultiplexesConcurrentRequests(t *testing.T) {
+	p := startHelper(t, "reverse", nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			label := fmt.Sprintf("LABEL_%d", i)
+			resp, err := p.Classify(t.Context(), "app", label+" message")
+			if err != nil || resp.LabelID != label {
+				errs <- fmt.Errorf("request %d: got %+v, %v", i, resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestPlugin_RestartsAfterCrash(t *testing.T) {
+	var restarts atomic.Int32
+	p := startHelper(t, "crash", &restarts)
+
+	if _, err := p.Classify(t.Context(), "app", "crash"); !errors.Is(err, ErrExited) {
+		t.Fatalf("expected the request in flight to fail, got %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := p.Classify(t.Context(), "app", "INFO back")
+		if err == nil {
+			if resp.LabelID != "INFO" {
+				t.Fatalf("unexpected answer %+v", resp)
+			}
+			break
+		}
+		if !errors.Is(err, ErrUnavailable) && !errors.Is(err, ErrExited) {
+			t.Fatalf("unexpected error while restarting: %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("plugin was not restarted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if restarts.Load() != 1 {
+		t.Fatalf("expected one restart, got %d", restarts.Load())
+	}
+}
+
+func TestPlugin_ClassifyHonorsContext(t *testing.T) {
+	p := startHelper(t, "reverse", nil) // holds a single request forever
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Classify(ctx, "app", "INFO alone"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestStart_FailsForMissingCommand(t *testing.T) {
+	if _, err := Start(Config{Name: "missing", Command: []string{"/nonexistent/plugin"}}); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
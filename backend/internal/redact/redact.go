@@ -0,0 +1,154 @@
+// Package redact finds sensitive spans in log messages (credentials,
+// tokens, card numbers, emails, ...) and replaces them with typed
+// placeholders before a message leaves the process.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Detector finds one kind of sensitive span. Placeholder may reference
+// capture groups, e.g. "${1}=<SECRET>" keeps the key of a key=value pair.
+type Detector struct {
+	Name        string
+	Pattern     string
+	Placeholder string
+	// Valid filters matches, e.g. a Luhn check for card numbers. Nil
+	// accepts every match.
+	Valid func(match string) bool
+}
+
+// Builtin returns the built-in detectors, most specific first.
+func Builtin() []Detector {
+	return []Detector{
+		{Name: "private_key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----(?s:.*?)(?:-----END [A-Z ]*PRIVATE KEY-----|$)`, Placeholder: "<PRIVATE_KEY>"},
+		{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`, Placeholder: "<JWT>"},
+		{Name: "bearer_token", Pattern: `(?i)\b(bearer)\s+[A-Za-z0-9._~+/-]+=*`, Placeholder: "${1} <TOKEN>"},
+		{Name: "aws_access_key", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`, Placeholder: "<AWS_KEY>"},
+		{Name: "credential", Pattern: `(?i)\b((?:password|passwd|pwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token|client[_-]?secret)["']?\s*[=:]\s*)("[^"]*"|'[^']*'|[^\s,;&]+)`, Placeholder: "${1}<SECRET>"},
+		{Name: "email", Pattern: `\b[\w.+-]+@[\w-]+(?:\.[\w-]+)+\b`, Placeholder: "<EMAIL>"},
+		{Name: "credit_card", Pattern: `\b(?:\d[ -]?){12,18}\d\b`, Placeholder: "<CREDIT_CARD>", Valid: luhn},
+	}
+}
+
+type detector struct {
+	Detector
+	re *regexp.Regexp
+}
+
+// Redactor applies detectors to messages.
+type Redactor struct {
+	detectors []detector
+}
+
+// New compiles the detectors. Detector names must be unique.
+func New(detectors []Detector) (*Redactor, error) {
+	r := &Redactor{}
+	seen := make(map[string]bool, len(detectors))
+	for _, d := range detectors {
+		if d.Name == "" {
+			return nil, fmt.Errorf("redact: detector without a name")
+		}
+		if seen[d.Name] {
+			return nil, fmt.Errorf("redact: duplicate detector %q", d.Name)
+		}
+		seen[d.Name] = true
+
+		re, err := regexp.Compile(d.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact detector %s: invalid pattern: %w", d.Name, err)
+		}
+		r.detectors = append(r.detectors, detector{Detector: d, re: re})
+	}
+	return r, nil
+}
+
+// Finding is a sensitive span in a message. Start and End are byte
+// offsets; Replacement is the placeholder text for the span.
+type Finding struct {
+	Detector    string
+	Start, End  int
+	Replacement string
+}
+
+// Find returns the sensitive spans in msg, in order of position. Spans
+// never overlap: where two detectors match the same text, the one listed
+// first wins.
+func (r *Redactor) Find(msg string) []Finding {
+	var findings []Finding
+	taken := func(start, end int) bool {
+		for _, f := range findings {
+			if start < f.End && f.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, d := range r.detectors {
+		for _, m := range d.re.FindAllStringSubmatchIndex(msg, -1) {
+			start, end := m[0], m[1]
+			if start == end || taken(start, end) {
+				continue
+			}
+			if d.Valid != nil && !d.Valid(msg[start:end]) {
+				continue
+			}
+			repl := string(d.re.ExpandString(nil, d.Placeholder, msg, m))
+			findings = append(findings, Finding{Detector: d.Name, Start: start, End: end, Replacement: repl})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Start < findings[j].Start })
+	return findings
+}
+
+// Redact replaces every sensitive span in msg with its placeholder and
+// returns the number of spans replaced per detector.
+func (r *Redactor) Redact(msg string) (string, map[string]int) {
+	findings := r.Find(msg)
+	if len(findings) == 0 {
+		return msg, nil
+	}
+
+	counts := make(map[string]int)
+	var out []byte
+	last := 0
+	for _, f := range findings {
+		out = append(out, msg[last:f.Start]...)
+		out = append(out, f.Replacement...)
+		last = f.End
+		counts[f.Detector]++
+	}
+	out = append(out, msg[last:]...)
+	return string(out), counts
+}
+
+// luhn reports whether the digits in s form a 13-19 digit number with a
+// valid Luhn check digit. Separators are ignored.
+func luhn(s string) bool {
+	var digits []int
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
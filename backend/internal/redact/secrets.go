@@ -0,0 +1,60 @@
+package redact
+
+import (
+	"math"
+	"strings"
+)
+
+// Secrets returns the detectors for leaked credentials. Unlike Builtin,
+// they skip personal data such as emails, and a password is only
+// reported when its value looks like a real secret rather than a
+// placeholder such as "***" or "<redacted>".
+func Secrets() []Detector {
+	var ds []Detector
+	for _, d := range Builtin() {
+		switch d.Name {
+		case "private_key", "jwt", "aws_access_key", "bearer_token":
+			ds = append(ds, d)
+		case "credential":
+			d.Valid = highEntropyValue
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
+
+// Minimum length and Shannon entropy (bits per character) of a credential
+// value to be reported as a leak.
+const (
+	minSecretLen     = 8
+	minSecretEntropy = 3.0
+)
+
+// highEntropyValue checks the value of a key=value or key: value match.
+func highEntropyValue(match string) bool {
+	i := strings.IndexAny(match, "=:")
+	if i < 0 {
+		return false
+	}
+	v := strings.Trim(strings.TrimSpace(match[i+1:]), `"'`)
+	if strings.HasPrefix(v, "<") || strings.HasPrefix(v, "${") || strings.HasPrefix(v, "%") {
+		return false // a placeholder, template variable or format verb
+	}
+	return len(v) >= minSecretLen && entropy(v) >= minSecretEntropy
+}
+
+// entropy is the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	var h float64
+	for _, k := range counts {
+		p := float64(k) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,91 @@
+package rules
+
+import "fmt"
+
+// Rule actions. A rule without an action labels the entry.
+const (
+	// ActionLabel classifies the entry and stops the pipeline.
+	ActionLabel = "label"
+	// ActionDrop suppresses the entry; no further stage runs.
+	ActionDrop = "drop"
+	// ActionTag adds Tags to the entry and keeps evaluating.
+	ActionTag = "tag"
+	// ActionRoute sends the entry to the sub-pipeline named by Route.
+	ActionRoute = "route"
+	// ActionRewrite replaces the matched text with the Rewrite template
+	// and keeps evaluating against the rewritten message.
+	ActionRewrite = "rewrite"
+)
+
+func actionOf(r Rule) string {
+	if r.Action == "" {
+		return ActionLabel
+	}
+	return r.Action
+}
+
+func checkAction(r Rule) error {
+	switch actionOf(r) {
+	case ActionLabel, ActionDrop:
+	case ActionTag:
+		if len(r.Tags) == 0 {
+			return fmt.Errorf("tag action needs tags")
+		}
+	case ActionRoute:
+		if r.Route == "" {
+			return fmt.Errorf("route action needs a route")
+		}
+	case ActionRewrite:
+		if r.Rewrite == "" {
+			return fmt.Errorf("rewrite action needs a rewrite template")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	return nil
+}
+
+// ActionName returns the rule's action, defaulting to ActionLabel.
+func (c *CompiledRule) ActionName() string {
+	return actionOf(c.Rule)
+}
+
+// Outcome is the result of evaluating a message against the rule set.
+type Outcome struct {
+	// Rule is the rule that ended evaluation (label, drop or route), or
+	// nil when no such rule matched.
+	Rule *CompiledRule
+	// Message is the message after any rewrites.
+	Message string
+	Tags    []string
+	// Applied lists the tag and rewrite rules that fired, in order.
+	Applied []*CompiledRule
+}
+
+// Evaluate runs the enabled rules in order. Tag and rewrite rules apply
+// and evaluation continues; the first label, drop or route rule ends it.
+func (c *Compiled) Evaluate(msg string) Outcome {
+	out := Outcome{Message: msg}
+	candidates := c.candidates(msg)
+	for i, r := range c.active {
+		if candidates != nil && !candidates[i] {
+			continue
+		}
+		if !r.re.MatchString(out.Message) {
+			continue
+		}
+		switch r.ActionName() {
+		case ActionTag:
+			out.Tags = append(out.Tags, r.Tags...)
+			out.Applied = append(out.Applied, r)
+		case ActionRewrite:
+			out.Message = r.re.ReplaceAllString(out.Message, r.Rewrite)
+			out.Applied = append(out.Applied, r)
+			candidates = c.candidates(out.Message)
+		default:
+			out.Rule = r
+			return out
+		}
+	}
+	return out
+}
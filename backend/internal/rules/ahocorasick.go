@@ -0,0 +1,78 @@
+package rules
+
+// acMatcher is an Aho-Corasick automaton over byte strings. It reports
+// which of its patterns occur anywhere in the input in a single pass.
+type acMatcher struct {
+	delta   [][256]int32 // full transition table
+	outputs [][]int      // pattern ids ending at each state, including via suffix links
+}
+
+func newACMatcher(patterns []string) *acMatcher {
+	m := &acMatcher{
+		delta:   make([][256]int32, 1),
+		outputs: make([][]int, 1),
+	}
+
+	// build the trie; -1 marks a missing edge until the BFS fills it in
+	for i := range m.delta[0] {
+		m.delta[0][i] = -1
+	}
+	for id, p := range patterns {
+		state := int32(0)
+		for i := 0; i < len(p); i++ {
+			next := m.delta[state][p[i]]
+			if next < 0 {
+				var row [256]int32
+				for j := range row {
+					row[j] = -1
+				}
+				m.delta = append(m.delta, row)
+				m.outputs = append(m.outputs, nil)
+				next = int32(len(m.delta) - 1)
+				m.delta[state][p[i]] = next
+			}
+			state = next
+		}
+		m.outputs[state] = append(m.outputs[state], id)
+	}
+
+	// breadth-first: compute failure links and complete the transitions
+	fail := make([]int32, len(m.delta))
+	queue := make([]int32, 0, len(m.delta))
+	for b := 0; b < 256; b++ {
+		if next := m.delta[0][b]; next > 0 {
+			fail[next] = 0
+			queue = append(queue, next)
+		} else {
+			m.delta[0][b] = 0
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		m.outputs[state] = append(m.outputs[state], m.outputs[fail[state]]...)
+
+		for b := 0; b < 256; b++ {
+			next := m.delta[state][b]
+			if next < 0 {
+				m.delta[state][b] = m.delta[fail[state]][b]
+				continue
+			}
+			fail[next] = m.delta[fail[state]][b]
+			queue = append(queue, next)
+		}
+	}
+	return m
+}
+
+// scan calls found for every pattern id occurring in s. An id may be
+// reported more than once.
+func (m *acMatcher) scan(s string, found func(id int)) {
+	state := int32(0)
+	for i := 0; i < len(s); i++ {
+		state = m.delta[state][s[i]]
+		for _, id := range m.outputs[state] {
+			found(id)
+		}
+	}
+}
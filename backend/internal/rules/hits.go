@@ -0,0 +1,102 @@
+package rules
+
+import (
+	"sync"
+	"time"
+)
+
+// MaxHitSeries bounds the number of distinct rule IDs exported as metric
+// labels. Rules seen after that, e.g. after heavy churn through the admin
+// API, are exported as OtherRule.
+const MaxHitSeries = 500
+
+// OtherRule is the metric label for rules beyond MaxHitSeries.
+const OtherRule = "other"
+
+// HitTracker remembers how often and when each rule last fired.
+type HitTracker struct {
+	mu      sync.Mutex
+	started time.Time
+	hits    map[string]*ruleHits
+	series  int
+	now     func() time.Time
+}
+
+type ruleHits struct {
+	count  uint64
+	last   time.Time
+	series string // metric label
+}
+
+func NewHitTracker() *HitTracker {
+	return &HitTracker{started: time.Now(), hits: make(map[string]*ruleHits), now: time.Now}
+}
+
+// Record counts a hit of rule id and returns the metric label to use
+// for it.
+func (t *HitTracker) Record(id string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.hits[id]
+	if !ok {
+		h = &ruleHits{series: OtherRule}
+		if t.series < MaxHitSeries {
+			h.series = id
+			t.series++
+		}
+		t.hits[id] = h
+	}
+	h.count++
+	h.last = t.now()
+	return h.series
+}
+
+// DeadRule is an enabled rule that did not fire within the window.
+type DeadRule struct {
+	ID      string     `json:"id"`
+	Label   string     `json:"label,omitempty"`
+	Action  string     `json:"action"`
+	Hits    uint64     `json:"hits"` // since the tracker started
+	LastHit *time.Time `json:"last_hit,omitempty"`
+}
+
+// DeadReport lists the rules with no hits since Since. Since is later
+// than the requested window start when the tracker has not been running
+// for the whole window; Complete is false then.
+type DeadReport struct {
+	Window   string     `json:"window"`
+	Since    time.Time  `json:"since"`
+	Complete bool       `json:"complete"`
+	Rules    []DeadRule `json:"rules"`
+}
+
+// Dead reports the enabled rules in c that have not fired within window.
+func (t *HitTracker) Dead(c *Compiled, window time.Duration) DeadReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := t.now().Add(-window)
+	report := DeadReport{Window: window.String(), Since: since, Complete: true, Rules: []DeadRule{}}
+	if t.started.After(since) {
+		report.Since = t.started
+		report.Complete = false
+	}
+
+	for _, r := range c.Rules() {
+		if !r.IsEnabled() {
+			continue
+		}
+		dead := DeadRule{ID: r.ID, Label: r.Label, Action: r.ActionName()}
+		if h, ok := t.hits[r.ID]; ok {
+			if !h.last.Before(since) {
+				continue
+			}
+			last := h.last
+			dead.Hits = h.count
+			dead.LastHit = &last
+		}
+		report.Rules = append(report.Rules, dead)
+	}
+	return report
+}
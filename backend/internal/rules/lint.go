@@ -0,0 +1,124 @@
+package rules
+
+import "fmt"
+
+// Lint finding kinds.
+const (
+	// LintShadowed: none of the rule's positive examples reach it because
+	// an earlier rule ends evaluation first.
+	LintShadowed = "shadowed"
+	// LintOverlap: a positive example of the rule is also matched by a
+	// rule with a different outcome, so their order decides the label.
+	LintOverlap = "overlap"
+)
+
+// LintFinding is a problem found by Lint.
+type LintFinding struct {
+	Kind       string `json:"kind"`
+	RuleID     string `json:"rule_id"`
+	OtherID    string `json:"other_id"`
+	Label      string `json:"label,omitempty"`
+	OtherLabel string `json:"other_label,omitempty"`
+	Example    string `json:"example"`
+}
+
+func (f LintFinding) String() string {
+	if f.Kind == LintShadowed {
+		return fmt.Sprintf("rule %s: shadowed by earlier rule %s, e.g. %q", f.RuleID, f.OtherID, f.Example)
+	}
+	return fmt.Sprintf("rule %s (%s) overlaps rule %s (%s) on %q", f.RuleID, f.Label, f.OtherID, f.OtherLabel, f.Example)
+}
+
+// outcome describes what a terminal rule does with a message, e.g.
+// "DB_ERROR" for a label rule or "drop".
+func (c *CompiledRule) outcome() string {
+	switch c.ActionName() {
+	case ActionLabel:
+		return c.Label
+	case ActionRoute:
+		return "route " + c.Route
+	}
+	return c.ActionName()
+}
+
+func (c *CompiledRule) terminal() bool {
+	switch c.ActionName() {
+	case ActionTag, ActionRewrite:
+		return false
+	}
+	return true
+}
+
+// Lint uses the enabled rules' positive examples to find rules that can
+// never fire and terminal rules that compete for the same messages with
+// different outcomes. Rules without positive examples are not checked.
+func (c *Compiled) Lint() []LintFinding {
+	var findings []LintFinding
+	reported := make(map[[2]string]bool)
+
+	for _, r := range c.active {
+		if len(r.Examples.Positive) == 0 {
+			continue
+		}
+
+		// shadowed: evaluation never gets to r for any of its examples
+		var shadow *LintFinding
+		for _, ex := range r.Examples.Positive {
+			out := c.Evaluate(ex)
+			if out.Rule == r || containsRule(out.Applied, r) || out.Rule == nil {
+				shadow = nil
+				break
+			}
+			if shadow == nil {
+				shadow = &LintFinding{Kind: LintShadowed, RuleID: r.ID, OtherID: out.Rule.ID, Example: ex}
+			}
+		}
+		if shadow != nil {
+			findings = append(findings, *shadow)
+			reported[pair(r.ID, shadow.OtherID)] = true
+			continue
+		}
+
+		if !r.terminal() {
+			continue
+		}
+		for _, ex := range r.Examples.Positive {
+			for _, other := range c.active {
+				if other == r || !other.terminal() || other.outcome() == r.outcome() {
+					continue
+				}
+				key := pair(r.ID, other.ID)
+				if reported[key] || !other.re.MatchString(ex) {
+					continue
+				}
+				reported[key] = true
+				findings = append(findings, LintFinding{
+					Kind:       LintOverlap,
+					RuleID:     r.ID,
+					OtherID:    other.ID,
+					Label:      r.outcome(),
+					OtherLabel: other.outcome(),
+					Example:    ex,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func containsRule(rs []*CompiledRule, r *CompiledRule) bool {
+	for _, x := range rs {
+		if x == r {
+			return true
+		}
+	}
+	return false
+}
+
+// pair is an order-independent key for two rule IDs.
+func pair(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
@@ -0,0 +1,97 @@
+package rules
+
+import (
+	"log-classifier/internal/taxonomy"
+	"testing"
+	"time"
+)
+
+func TestLint_ShadowedAndOverlapping(t *testing.T) {
+	c, err := compile(t, `
+rules:
+  - id: any-error
+    pattern: '(?i)error'
+    label: ERROR
+    examples: {positive: ["error in module"]}
+  - id: db-error
+    pattern: '(?i)database error'
+    label: DB_ERROR
+    examples: {positive: ["Database error: connection reset"]}
+  - id: login
+    pattern: 'logged in'
+    label: USER_ACTION
+    examples: {positive: ["User bob logged in", "login error: bob logged in twice"]}
+  - id: harmless
+    pattern: 'heartbeat'
+    label: INFO
+    examples: {positive: ["heartbeat ok"]}
+`)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	findings := c.Lint()
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %v", findings)
+	}
+	if f := findings[0]; f.Kind != LintShadowed || f.RuleID != "db-error" || f.OtherID != "any-error" {
+		t.Errorf("expected db-error shadowed by any-error, got %s", f)
+	}
+	if f := findings[1]; f.Kind != LintOverlap || f.RuleID != "login" || f.OtherID != "any-error" || f.OtherLabel != "ERROR" {
+		t.Errorf("expected login to overlap any-error, got %s", f)
+	}
+}
+
+func TestLint_DefaultRulesAreClean(t *testing.T) {
+	c, err := Compile(Default(), taxonomy.Current())
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if findings := c.Lint(); len(findings) > 0 {
+		t.Fatalf("built-in rules have lint findings: %v", findings)
+	}
+}
+
+func TestHitTracker_DeadRules(t *testing.T) {
+	c, err := compile(t, `
+rules:
+  - {id: a, pattern: 'a', label: INFO}
+  - {id: b, pattern: 'b', label: INFO}
+  - {id: c, pattern: 'c', label: INFO, enabled: false}
+`)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tr := NewHitTracker()
+	tr.started = now.Add(-48 * time.Hour)
+	tr.now = func() time.Time { return now.Add(-36 * time.Hour) }
+	tr.Record("b")
+	tr.now = func() time.Time { return now.Add(-time.Hour) }
+	tr.Record("a")
+	tr.now = func() time.Time { return now }
+
+	report := tr.Dead(c, 24*time.Hour)
+	if !report.Complete || len(report.Rules) != 1 || report.Rules[0].ID != "b" || report.Rules[0].Hits != 1 {
+		t.Fatalf("expected only b to be dead, got %+v", report)
+	}
+
+	report = tr.Dead(c, 72*time.Hour)
+	if report.Complete || !report.Since.Equal(tr.started) || len(report.Rules) != 0 {
+		t.Fatalf("expected an incomplete window with no dead rules, got %+v", report)
+	}
+}
+
+func TestHitTracker_BoundsSeries(t *testing.T) {
+	tr := NewHitTracker()
+	for i := 0; i < MaxHitSeries; i++ {
+		tr.Record(string(rune('a'+i%26)) + time.Duration(i).String())
+	}
+	if got := tr.Record("one-too-many"); got != OtherRule {
+		t.Fatalf("expected %q beyond the series limit, got %q", OtherRule, got)
+	}
+	if got := tr.Record("a0s"); got != "a0s" {
+		t.Fatalf("expected known rule to keep its series, got %q", got)
+	}
+}
@@ -0,0 +1,150 @@
+package rules
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minLiteralLen is the shortest required literal worth prefiltering on.
+// Shorter literals match almost every message and only add overhead.
+const minLiteralLen = 3
+
+// prefilter narrows a message down to the rules that can possibly match
+// it. Each rule contributes the literals at least one of which must occur
+// in any match; rules without such literals are always candidates.
+// Literals and messages are compared case-folded.
+type prefilter struct {
+	matcher  *acMatcher
+	literals []int // literal id -> index into the rule list
+	always   []int // rules without required literals
+	n        int
+}
+
+func newPrefilter(rules []*CompiledRule) *prefilter {
+	pf := &prefilter{n: len(rules)}
+
+	var patterns []string
+	for i, r := range rules {
+		lits := requiredLiterals(r.Pattern)
+		if lits == nil {
+			pf.always = append(pf.always, i)
+			continue
+		}
+		for _, lit := range lits {
+			patterns = append(patterns, lit)
+			pf.literals = append(pf.literals, i)
+		}
+	}
+	pf.matcher = newACMatcher(patterns)
+	return pf
+}
+
+// candidates marks the rules that may match msg.
+func (pf *prefilter) candidates(msg string) []bool {
+	out := make([]bool, pf.n)
+	for _, i := range pf.always {
+		out[i] = true
+	}
+	pf.matcher.scan(foldString(msg), func(id int) {
+		out[pf.literals[id]] = true
+	})
+	return out
+}
+
+// requiredLiterals returns a set of case-folded literals at least one of
+// which occurs in every match of pattern, or nil if there is none.
+func requiredLiterals(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	lits := required(re.Simplify())
+	for _, l := range lits {
+		if len(l) < minLiteralLen {
+			return nil
+		}
+	}
+	return lits
+}
+
+func required(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{foldString(string(re.Rune))}
+
+	case syntax.OpCapture, syntax.OpPlus:
+		return required(re.Sub[0])
+
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return required(re.Sub[0])
+		}
+		return nil
+
+	case syntax.OpConcat:
+		// any child's requirement holds for the whole; keep the one whose
+		// shortest literal is longest, as it filters best
+		var best []string
+		for _, sub := range re.Sub {
+			if lits := required(sub); lits != nil && shortest(lits) > shortest(best) {
+				best = lits
+			}
+		}
+		return best
+
+	case syntax.OpAlternate:
+		var all []string
+		for _, sub := range re.Sub {
+			lits := required(sub)
+			if lits == nil {
+				return nil
+			}
+			all = append(all, lits...)
+		}
+		return all
+	}
+	return nil
+}
+
+func shortest(lits []string) int {
+	if len(lits) == 0 {
+		return 0
+	}
+	n := len(lits[0])
+	for _, l := range lits[1:] {
+		n = min(n, len(l))
+	}
+	return n
+}
+
+// foldString maps every rune onto the smallest rune of its case folding
+// orbit, so that strings equal under (?i) fold to the same bytes.
+func foldString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteRune(foldRune(r))
+		i += size
+	}
+	return b.String()
+}
+
+func foldRune(r rune) rune {
+	lowest := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		lowest = min(lowest, f)
+	}
+	return lowest
+}
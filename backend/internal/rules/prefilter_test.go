@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"fmt"
+	"log-classifier/internal/taxonomy"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{`(?i)User \w+ logged (in|out)`, []string{" LOGGED "}},
+		{`(?i)Backup (started|ended) at .+`, []string{"BACKUP "}},
+		{`(?i)(started|ended) at`, []string{"STARTED", "ENDED"}},
+		{`disk (full|quota exceeded)`, []string{"DISK "}},
+		{`(error|warn)`, []string{"ERROR", "WARN"}},
+		{`(error|\d+)`, nil},
+		{`a.b`, nil},
+		{`(?:timeout){2,}`, []string{"TIMEOUT"}},
+		{`(?:timeout)*`, nil},
+	}
+	for _, c := range cases {
+		got := requiredLiterals(c.pattern)
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%s: expected %q, got %q", c.pattern, c.want, got)
+		}
+	}
+}
+
+func TestFoldString_MatchesRegexpCaseFolding(t *testing.T) {
+	// KELVIN SIGN and LATIN SMALL LETTER LONG S fold onto k and s under (?i)
+	if foldString("Key") != foldString("KEY") || foldString("ſession") != foldString("session") {
+		t.Fatalf("fold orbits are not canonical")
+	}
+}
+
+// generatedRules builds n rules with a mix of literal, alternation and
+// literal-free patterns.
+func generatedRules(n int) *RuleSet {
+	words := []string{"disk", "memory", "timeout", "login", "backup", "upload", "queue", "shard", "cache", "token"}
+	rs := &RuleSet{Version: "bench"}
+	for i := 0; i < n; i++ {
+		w1, w2 := words[i%len(words)], words[(i/len(words))%len(words)]
+		var pattern string
+		switch i % 4 {
+		case 0:
+			pattern = fmt.Sprintf(`(?i)%s \w+ failed with code %d`, w1, i)
+		case 1:
+			pattern = fmt.Sprintf(`(%s|%s)-%d exceeded`, w1, w2, i)
+		case 2:
+			pattern = fmt.Sprintf(`(?i)service%d: %s .+ %s`, i, w1, w2)
+		default:
+			pattern = fmt.Sprintf(`^\d+ %c\w* %d$`, w1[0], i)
+		}
+		rs.Rules = append(rs.Rules, Rule{ID: fmt.Sprintf("r%d", i), Pattern: pattern, Label: "INFO"})
+	}
+	return rs
+}
+
+func generatedMessages(n int) []string {
+	r := rand.New(rand.NewPCG(3, 4))
+	words := []string{"disk", "MEMORY", "timeout", "Login", "backup", "upload", "queue", "shard", "cache", "token", "Key"}
+	msgs := make([]string, n)
+	for i := range msgs {
+		w1, w2 := words[r.IntN(len(words))], words[r.IntN(len(words))]
+		k := r.IntN(400)
+		switch r.IntN(5) {
+		case 0:
+			msgs[i] = fmt.Sprintf("%s node7 failed with code %d", w1, k)
+		case 1:
+			msgs[i] = fmt.Sprintf("%s-%d exceeded", w1, k)
+		case 2:
+			msgs[i] = fmt.Sprintf("SERVICE%d: %s on host %s", k, w1, w2)
+		case 3:
+			msgs[i] = fmt.Sprintf("%d %s %d", r.IntN(100), w1, k)
+		default:
+			msgs[i] = fmt.Sprintf("unrelated message about %s and %s", w1, w2)
+		}
+	}
+	return msgs
+}
+
+func TestPrefilter_MatchesSequentialEvaluation(t *testing.T) {
+	c, err := Compile(generatedRules(400), taxonomy.Current())
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	sequential := *c
+	sequential.filter = nil
+
+	matched := 0
+	for _, msg := range generatedMessages(5000) {
+		got, want := c.Match(msg), sequential.Match(msg)
+		if got != want {
+			t.Fatalf("%q: prefiltered match %v differs from sequential %v", msg, got, want)
+		}
+		if got != nil {
+			matched++
+		}
+	}
+	if matched == 0 {
+		t.Fatalf("test messages never matched; the comparison is meaningless")
+	}
+}
+
+func benchmarkMatch(b *testing.B, prefiltered bool) {
+	c, err := Compile(generatedRules(500), taxonomy.Current())
+	if err != nil {
+		b.Fatalf("compile: %v", err)
+	}
+	if !prefiltered {
+		c.filter = nil
+	}
+	msgs := generatedMessages(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Match(msgs[i%len(msgs)])
+	}
+}
+
+func BenchmarkMatch_Sequential(b *testing.B)  { benchmarkMatch(b, false) }
+func BenchmarkMatch_Prefiltered(b *testing.B) { benchmarkMatch(b, true) }
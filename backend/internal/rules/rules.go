@@ -0,0 +1,307 @@
+package rules
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"log-classifier/internal/taxonomy"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// DefaultConfidence is used for rules that do not declare one.
+const DefaultConfidence = 0.95
+
+//go:embed default.yaml
+var defaultRules []byte
+
+// Rule is one regex rule as written in a rule file.
+type Rule struct {
+	ID         string  `yaml:"id" json:"id"`
+	Pattern    string  `yaml:"pattern" json:"pattern"`
+	Label      string  `yaml:"label" json:"label"`
+	Confidence float64 `yaml:"confidence,omitempty" json:"confidence,omitempty"`
+	Priority   int     `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// LabelTemplate and SubType may reference named capture groups, e.g.
+	// "${level}_ERROR" or "log${action}". A rendered label that is not in
+	// the taxonomy falls back to Label.
+	LabelTemplate string `yaml:"label_template,omitempty" json:"label_template,omitempty"`
+	SubType       string `yaml:"sub_type,omitempty" json:"sub_type,omitempty"`
+
+	// Action is what happens when the rule matches; see actions.go.
+	Action  string   `yaml:"action,omitempty" json:"action,omitempty"`
+	Tags    []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Route   string   `yaml:"route,omitempty" json:"route,omitempty"`
+	Rewrite string   `yaml:"rewrite,omitempty" json:"rewrite,omitempty"`
+
+	Enabled  *bool    `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Examples Examples `yaml:"examples,omitempty" json:"examples,omitempty"`
+}
+
+// Examples are messages the rule must (positive) or must not (negative)
+// match. They are checked before a rule set is accepted.
+type Examples struct {
+	Positive []string `yaml:"positive,omitempty" json:"positive,omitempty"`
+	Negative []string `yaml:"negative,omitempty" json:"negative,omitempty"`
+}
+
+func (r Rule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// RuleSet is the content of a rule file.
+type RuleSet struct {
+	Version string `yaml:"version" json:"version"`
+	Rules   []Rule `yaml:"rules" json:"rules"`
+}
+
+// Parse reads a YAML (or JSON) rule file.
+func Parse(data []byte) (*RuleSet, error) {
+	var rs RuleSet
+	if err := yaml.UnmarshalStrict(data, &rs); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+	return &rs, nil
+}
+
+func Load(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules: %w", err)
+	}
+	rs, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// Default returns the built-in rule set.
+func Default() *RuleSet {
+	rs, err := Parse(defaultRules)
+	if err != nil {
+		panic(err)
+	}
+	return rs
+}
+
+// CompiledRule is a validated rule ready for matching.
+type CompiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+func (c *CompiledRule) MatchString(msg string) bool {
+	return c.re.MatchString(msg)
+}
+
+// Extraction is what a matching rule pulled out of a message.
+type Extraction struct {
+	Entities map[string]string
+	LabelID  string
+	SubType  string
+}
+
+// Extract returns the named capture groups of msg and renders the rule's
+// templates. msg is expected to match the rule.
+func (c *CompiledRule) Extract(msg string) Extraction {
+	ex := Extraction{LabelID: c.Label}
+	if c.re.NumSubexp() == 0 {
+		ex.SubType = c.SubType
+		return ex
+	}
+
+	idx := c.re.FindStringSubmatchIndex(msg)
+	if idx == nil {
+		return ex
+	}
+	for i, name := range c.re.SubexpNames() {
+		if name == "" || idx[2*i] < 0 {
+			continue
+		}
+		if ex.Entities == nil {
+			ex.Entities = make(map[string]string)
+		}
+		ex.Entities[name] = msg[idx[2*i]:idx[2*i+1]]
+	}
+
+	if c.LabelTemplate != "" {
+		if label := string(c.re.ExpandString(nil, c.LabelTemplate, msg, idx)); label != "" {
+			ex.LabelID = label
+		}
+	}
+	if c.SubType != "" {
+		ex.SubType = string(c.re.ExpandString(nil, c.SubType, msg, idx))
+	}
+	return ex
+}
+
+var templateVar = regexp.MustCompile(`\$\{?(\w+)\}?`)
+
+// checkTemplate makes sure a template only references groups of re.
+func checkTemplate(re *regexp.Regexp, tmpl string) error {
+	for _, m := range templateVar.FindAllStringSubmatch(tmpl, -1) {
+		name := m[1]
+		if n, err := strconv.Atoi(name); err == nil {
+			if n > re.NumSubexp() {
+				return fmt.Errorf("template %q references unknown group %s", tmpl, name)
+			}
+			continue
+		}
+		if re.SubexpIndex(name) < 0 {
+			return fmt.Errorf("template %q references unknown group %q", tmpl, name)
+		}
+	}
+	return nil
+}
+
+// Compiled is a validated rule set. Enabled rules are kept in match order:
+// highest priority first, then file order.
+type Compiled struct {
+	Version string
+	all     []*CompiledRule
+	active  []*CompiledRule
+	filter  *prefilter // nil evaluates every active rule
+}
+
+// ErrEmptyPattern rejects a rule whose pattern is empty or only
+// whitespace, which would match every message.
+var ErrEmptyPattern = errors.New("empty pattern")
+
+// Compile validates every rule against the taxonomy, compiles the
+// patterns and checks that every example behaves as declared.
+func Compile(rs *RuleSet, reg *taxonomy.Registry) (*Compiled, error) {
+	c := &Compiled{Version: rs.Version}
+	seen := make(map[string]bool, len(rs.Rules))
+
+	for i, r := range rs.Rules {
+		if r.ID == "" {
+			return nil, fmt.Errorf("rule %d: missing id", i)
+		}
+		if seen[r.ID] {
+			return nil, fmt.Errorf("rule %s: duplicate id", r.ID)
+		}
+		seen[r.ID] = true
+
+		if err := checkAction(r); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		if _, ok := reg.Lookup(r.Label); !ok && (r.Label != "" || actionOf(r) == ActionLabel) {
+			return nil, fmt.Errorf("rule %s: unknown label %q", r.ID, r.Label)
+		}
+		if r.Confidence == 0 {
+			r.Confidence = DefaultConfidence
+		}
+		if r.Confidence < 0 || r.Confidence > 1 {
+			return nil, fmt.Errorf("rule %s: confidence must be between 0 and 1, got %v", r.ID, r.Confidence)
+		}
+
+		if strings.TrimSpace(r.Pattern) == "" {
+			return nil, fmt.Errorf("rule %s: %w", r.ID, ErrEmptyPattern)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: invalid pattern: %w", r.ID, err)
+		}
+		for _, tmpl := range []string{r.LabelTemplate, r.SubType, r.Rewrite} {
+			if err := checkTemplate(re, tmpl); err != nil {
+				return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+			}
+		}
+
+		cr := &CompiledRule{Rule: r, re: re}
+		c.all = append(c.all, cr)
+		if r.IsEnabled() {
+			c.active = append(c.active, cr)
+		}
+	}
+
+	sort.SliceStable(c.active, func(i, j int) bool {
+		return c.active[i].Priority > c.active[j].Priority
+	})
+	c.filter = newPrefilter(c.active)
+
+	if failures := c.Test(); len(failures) > 0 {
+		return nil, &ExampleError{Failures: failures}
+	}
+	return c, nil
+}
+
+// Match returns the first enabled rule matching msg, or nil, regardless
+// of its action.
+func (c *Compiled) Match(msg string) *CompiledRule {
+	candidates := c.candidates(msg)
+	for i, r := range c.active {
+		if candidates != nil && !candidates[i] {
+			continue
+		}
+		if r.re.MatchString(msg) {
+			return r
+		}
+	}
+	return nil
+}
+
+// candidates returns which active rules may match msg, or nil if every
+// rule has to be tried.
+func (c *Compiled) candidates(msg string) []bool {
+	if c.filter == nil {
+		return nil
+	}
+	return c.filter.candidates(msg)
+}
+
+// Rules returns every rule, enabled or not, in file order.
+func (c *Compiled) Rules() []*CompiledRule {
+	return c.all
+}
+
+// ExampleFailure is an example that did not behave as declared.
+type ExampleFailure struct {
+	RuleID   string `json:"rule_id"`
+	Example  string `json:"example"`
+	Positive bool   `json:"positive"`
+}
+
+func (f ExampleFailure) String() string {
+	if f.Positive {
+		return fmt.Sprintf("rule %s: positive example does not match: %q", f.RuleID, f.Example)
+	}
+	return fmt.Sprintf("rule %s: negative example matches: %q", f.RuleID, f.Example)
+}
+
+type ExampleError struct {
+	Failures []ExampleFailure
+}
+
+func (e *ExampleError) Error() string {
+	msg := fmt.Sprintf("%d rule example(s) failed", len(e.Failures))
+	for _, f := range e.Failures {
+		msg += "\n  " + f.String()
+	}
+	return msg
+}
+
+// Test checks every rule's examples against its own pattern.
+func (c *Compiled) Test() []ExampleFailure {
+	var failures []ExampleFailure
+	for _, r := range c.all {
+		for _, ex := range r.Examples.Positive {
+			if !r.re.MatchString(ex) {
+				failures = append(failures, ExampleFailure{RuleID: r.ID, Example: ex, Positive: true})
+			}
+		}
+		for _, ex := range r.Examples.Negative {
+			if r.re.MatchString(ex) {
+				failures = append(failures, ExampleFailure{RuleID: r.ID, Example: ex})
+			}
+		}
+	}
+	return failures
+}
@@ -0,0 +1,129 @@
+package rules
+
+import (
+	"errors"
+	"log-classifier/internal/taxonomy"
+	"strings"
+	"testing"
+)
+
+func compile(t *testing.T, src string) (*Compiled, error) {
+	t.Helper()
+	rs, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return Compile(rs, taxonomy.Current())
+}
+
+func TestDefaultRulesCompile(t *testing.T) {
+	c, err := Compile(Default(), taxonomy.Current())
+	if err != nil {
+		t.Fatalf("built-in rules: %v", err)
+	}
+	if r := c.Match("User admin logged in"); r == nil || r.ID != "user-login-logout" {
+		t.Fatalf("expected user-login-logout, got %+v", r)
+	}
+}
+
+func TestCompile_RejectsInvalidRules(t *testing.T) {
+	cases := map[string]string{
+		"unknown label": `rules: [{id: a, pattern: 'x', label: NOPE}]`,
+		"bad pattern":   `rules: [{id: a, pattern: '(', label: INFO}]`,
+		"duplicate id":  `rules: [{id: a, pattern: 'x', label: INFO}, {id: a, pattern: 'y', label: INFO}]`,
+		"missing id":    `rules: [{pattern: 'x', label: INFO}]`,
+		"empty pattern": `rules: [{id: a, pattern: '', label: INFO}]`,
+		"blank pattern": `rules: [{id: a, pattern: '  ', label: INFO}]`,
+	}
+	for name, src := range cases {
+		if _, err := compile(t, src); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCompile_ChecksExamples(t *testing.T) {
+	_, err := compile(t, `
+rules:
+  - id: disk
+    pattern: 'disk full'
+    label: INFO
+    examples:
+      positive: ["disk full on /var", "Disk full"]
+      negative: ["disk full warning"]
+`)
+	var exErr *ExampleError
+	if !errors.As(err, &exErr) {
+		t.Fatalf("expected ExampleError, got %v", err)
+	}
+	if len(exErr.Failures) != 2 {
+		t.Fatalf("expected 2 failures, got %v", exErr.Failures)
+	}
+	if !strings.Contains(err.Error(), `"Disk full"`) {
+		t.Fatalf("error should name the failing example: %v", err)
+	}
+}
+
+func TestMatch_PriorityAndEnabled(t *testing.T) {
+	c, err := compile(t, `
+rules:
+  - id: broad
+    pattern: 'error'
+    label: ERROR
+  - id: specific
+    pattern: 'database error'
+    label: DB_ERROR
+    priority: 10
+  - id: off
+    pattern: 'disabled'
+    label: INFO
+    enabled: false
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r := c.Match("database error on shard 3"); r == nil || r.ID != "specific" {
+		t.Fatalf("expected higher priority rule, got %+v", r)
+	}
+	if r := c.Match("disabled rule"); r != nil {
+		t.Fatalf("disabled rule matched")
+	}
+	if r := c.Match("generic error"); r == nil || r.Confidence != DefaultConfidence {
+		t.Fatalf("expected default confidence, got %+v", r)
+	}
+}
+
+func TestExtract_EntitiesAndTemplates(t *testing.T) {
+	c, err := compile(t, `
+rules:
+  - id: level-error
+    pattern: '(?P<component>\w+): (?P<kind>AUTH|DB) failure for (?P<user>\w+)'
+    label: ERROR
+    label_template: '${kind}_ERROR'
+    sub_type: '${component}'
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg := "payments: DB failure for alice"
+	r := c.Match(msg)
+	if r == nil {
+		t.Fatalf("expected a match")
+	}
+	ex := r.Extract(msg)
+	if ex.LabelID != "DB_ERROR" || ex.SubType != "payments" {
+		t.Fatalf("unexpected templates: %+v", ex)
+	}
+	if ex.Entities["user"] != "alice" || ex.Entities["kind"] != "DB" || len(ex.Entities) != 3 {
+		t.Fatalf("unexpected entities: %v", ex.Entities)
+	}
+}
+
+func TestCompile_RejectsUnknownTemplateGroups(t *testing.T) {
+	_, err := compile(t, `rules: [{id: a, pattern: '(?P<user>\w+)', label: INFO, sub_type: '${usr}'}]`)
+	if err == nil || !strings.Contains(err.Error(), "usr") {
+		t.Fatalf("expected unknown group error, got %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package rules
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log-classifier/internal/taxonomy"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRuleNotFound = errors.New("rule not found")
+	ErrRuleExists   = errors.New("rule already exists")
+	ErrNoSuchRev    = errors.New("no such revision")
+)
+
+// AuditEntry records one change to the rule set. Rules is a full snapshot
+// of the rule set after the change, so any revision can be restored.
+type AuditEntry struct {
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	Actor    string    `json:"actor"`
+	Action   string    `json:"action"`
+	RuleID   string    `json:"rule_id,omitempty"`
+	Before   *Rule     `json:"before,omitempty"`
+	After    *Rule     `json:"after,omitempty"`
+	Version  string    `json:"version"`
+	Rules    []Rule    `json:"rules,omitempty"`
+}
+
+// Store manages the live rule set. Every change is validated, written to
+// an append-only audit log and then applied atomically through onChange.
+type Store struct {
+	mu       sync.Mutex
+	registry func() *taxonomy.Registry
+	routes   map[string]bool
+	onChange func(*Compiled)
+	auditLog string
+	history  []AuditEntry
+	current  *RuleSet
+}
+
+// OpenStore starts from the given rule set, or from the latest snapshot in
+// the audit log at auditPath if it has one. An empty auditPath keeps the
+// history in memory only. Route rules must name one of routes.
+func OpenStore(initial *RuleSet, registry func() *taxonomy.Registry, routes []string, auditPath string, onChange func(*Compiled)) (*Store, error) {
+	s := &Store{registry: registry, routes: make(map[string]bool, len(routes)), onChange: onChange, auditLog: auditPath}
+	for _, r := range routes {
+		s.routes[r] = true
+	}
+
+	if auditPath != "" {
+		history, err := readAudit(auditPath)
+		if err != nil {
+			return nil, err
+		}
+		s.history = history
+	}
+
+	if len(s.history) > 0 {
+		last := s.history[len(s.history)-1]
+		initial = &RuleSet{Version: last.Version, Rules: last.Rules}
+	}
+
+	c, err := s.compile(initial)
+	if err != nil {
+		return nil, err
+	}
+	s.current = initial
+
+	if len(s.history) == 0 {
+		entry := AuditEntry{Time: time.Now().UTC(), Actor: "system", Action: "load", Version: initial.Version, Rules: initial.Rules}
+		if err := s.append(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	onChange(c)
+	return s, nil
+}
+
+func readAudit(path string) ([]AuditEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var history []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("audit log %s: %w", path, err)
+		}
+		history = append(history, e)
+	}
+	return history, scanner.Err()
+}
+
+// append writes the entry to the audit log before it is recorded in memory.
+func (s *Store) append(e AuditEntry) error {
+	e.Revision = len(s.history)
+	if s.auditLog != "" {
+		f, err := os.OpenFile(s.auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		defer f.Close()
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("failed to sync audit log: %w", err)
+		}
+	}
+	s.history = append(s.history, e)
+	return nil
+}
+
+// compile is Compile that also checks that route rules name a configured
+// route, which Compile cannot know about.
+func (s *Store) compile(rs *RuleSet) (*Compiled, error) {
+	for _, r := range rs.Rules {
+		if actionOf(r) == ActionRoute && !s.routes[r.Route] {
+			return nil, fmt.Errorf("rule %s: unknown route %q", r.ID, r.Route)
+		}
+	}
+	return Compile(rs, s.registry())
+}
+
+// commit validates next, records it and swaps it in. Callers hold s.mu.
+func (s *Store) commit(next *RuleSet, actor, action, ruleID string, before, after *Rule) (AuditEntry, error) {
+	c, err := s.compile(next)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+
+	entry := AuditEntry{
+		Time:    time.Now().UTC(),
+		Actor:   actor,
+		Action:  action,
+		RuleID:  ruleID,
+		Before:  before,
+		After:   after,
+		Version: next.Version,
+		Rules:   next.Rules,
+	}
+	if err := s.append(entry); err != nil {
+		return AuditEntry{}, err
+	}
+
+	s.current = next
+	s.onChange(c)
+	return s.history[len(s.history)-1], nil
+}
+
+func (s *Store) index(id string) int {
+	return slices.IndexFunc(s.current.Rules, func(r Rule) bool { return r.ID == id })
+}
+
+// Snapshot returns the current rule set and its revision.
+func (s *Store) Snapshot() (RuleSet, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return RuleSet{Version: s.current.Version, Rules: slices.Clone(s.current.Rules)}, len(s.history) - 1
+}
+
+// Lint runs Lint on the current rule set.
+func (s *Store) Lint() ([]LintFinding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, err := Compile(s.current, s.registry())
+	if err != nil {
+		return nil, err
+	}
+	return c.Lint(), nil
+}
+
+func (s *Store) Create(actor string, r Rule) (AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index(r.ID) >= 0 {
+		return AuditEntry{}, fmt.Errorf("%w: %s", ErrRuleExists, r.ID)
+	}
+	next := &RuleSet{Version: s.current.Version, Rules: append(slices.Clone(s.current.Rules), r)}
+	return s.commit(next, actor, "create", r.ID, nil, &r)
+}
+
+func (s *Store) Update(actor, id string, r Rule) (AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return AuditEntry{}, fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+	r.ID = id
+	before := s.current.Rules[i]
+
+	next := &RuleSet{Version: s.current.Version, Rules: slices.Clone(s.current.Rules)}
+	next.Rules[i] = r
+	return s.commit(next, actor, "update", id, &before, &r)
+}
+
+// SetEnabled enables or disables a rule without removing it.
+func (s *Store) SetEnabled(actor, id string, enabled bool) (AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return AuditEntry{}, fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+	before := s.current.Rules[i]
+	after := before
+	after.Enabled = &enabled
+
+	next := &RuleSet{Version: s.current.Version, Rules: slices.Clone(s.current.Rules)}
+	next.Rules[i] = after
+
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	return s.commit(next, actor, action, id, &before, &after)
+}
+
+func (s *Store) Delete(actor, id string) (AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return AuditEntry{}, fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+	before := s.current.Rules[i]
+
+	next := &RuleSet{Version: s.current.Version, Rules: slices.Delete(slices.Clone(s.current.Rules), i, i+1)}
+	return s.commit(next, actor, "delete", id, &before, nil)
+}
+
+// Rollback restores the rule set as it was at the given revision. The
+// rollback itself is recorded as a new revision.
+func (s *Store) Rollback(actor string, revision int) (AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if revision < 0 || revision >= len(s.history) {
+		return AuditEntry{}, fmt.Errorf("%w: %d", ErrNoSuchRev, revision)
+	}
+	target := s.history[revision]
+
+	next := &RuleSet{Version: target.Version, Rules: slices.Clone(target.Rules)}
+	return s.commit(next, actor, fmt.Sprintf("rollback to %d", revision), "", nil, nil)
+}
+
+// History returns the audit entries, oldest first.
+func (s *Store) History() []AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.history)
+}
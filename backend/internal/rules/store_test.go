@@ -0,0 +1,115 @@
+package rules
+
+import (
+	"errors"
+	"log-classifier/internal/taxonomy"
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T, auditPath string) (*Store, **Compiled) {
+	t.Helper()
+	live := new(*Compiled)
+	s, err := OpenStore(Default(), taxonomy.Current, []string{"security"}, auditPath, func(c *Compiled) { *live = c })
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	return s, live
+}
+
+func TestStore_ChangesApplyAndAreAudited(t *testing.T) {
+	s, live := openTestStore(t, "")
+
+	rule := Rule{ID: "disk-full", Pattern: `(?i)disk full`, Label: "SYSTEM_NOTIFICATION",
+		Examples: Examples{Positive: []string{"Disk full on /var"}}}
+	if _, err := s.Create("alice", rule); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if r := (*live).Match("disk full on /data"); r == nil || r.ID != "disk-full" {
+		t.Fatalf("new rule is not live")
+	}
+
+	if _, err := s.SetEnabled("bob", "disk-full", false); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if r := (*live).Match("disk full on /data"); r != nil {
+		t.Fatalf("disabled rule still matches")
+	}
+
+	history := s.History()
+	if len(history) != 3 {
+		t.Fatalf("expected load, create and disable entries, got %d", len(history))
+	}
+	last := history[2]
+	if last.Actor != "bob" || last.Action != "disable" || last.Before == nil || last.After == nil {
+		t.Fatalf("unexpected audit entry: %+v", last)
+	}
+}
+
+func TestStore_RejectsInvalidChanges(t *testing.T) {
+	s, live := openTestStore(t, "")
+	before := *live
+
+	bad := Rule{ID: "bad", Pattern: `disk full`, Label: "INFO",
+		Examples: Examples{Negative: []string{"disk full"}}}
+	var exErr *ExampleError
+	if _, err := s.Create("alice", bad); !errors.As(err, &exErr) {
+		t.Fatalf("expected example failure, got %v", err)
+	}
+	if _, err := s.Delete("alice", "nope"); !errors.Is(err, ErrRuleNotFound) {
+		t.Fatalf("expected ErrRuleNotFound, got %v", err)
+	}
+	if _, err := s.Create("alice", Rule{ID: "system-updated", Pattern: "x", Label: "INFO"}); !errors.Is(err, ErrRuleExists) {
+		t.Fatalf("expected ErrRuleExists, got %v", err)
+	}
+
+	if *live != before || len(s.History()) != 1 {
+		t.Fatalf("rejected changes must not be applied or audited")
+	}
+}
+
+func TestStore_RollbackAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	s, _ := openTestStore(t, path)
+
+	if _, err := s.Delete("alice", "system-updated"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.Rollback("alice", 0); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if _, err := s.Delete("alice", "disk-cleanup"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	// a restart picks up the latest snapshot from the audit log
+	reopened, live := openTestStore(t, path)
+	if len(reopened.History()) != 4 {
+		t.Fatalf("expected 4 audit entries after reload, got %d", len(reopened.History()))
+	}
+	if (*live).Match("System updated to version 2") == nil {
+		t.Fatalf("rolled back rule missing after reload")
+	}
+	if (*live).Match("Disk cleanup completed successfully") != nil {
+		t.Fatalf("deleted rule present after reload")
+	}
+}
+
+func TestStore_RejectsUnknownRoutes(t *testing.T) {
+	s, _ := openTestStore(t, "")
+
+	rule := Rule{ID: "intrusion", Pattern: `(?i)intrusion`, Action: ActionRoute, Route: "secuirty"}
+	if _, err := s.Create("alice", rule); err == nil || err.Error() != `rule intrusion: unknown route "secuirty"` {
+		t.Fatalf("expected the unknown route to be rejected, got %v", err)
+	}
+	rule.Route = "security"
+	if _, err := s.Create("alice", rule); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	initial := Default()
+	initial.Rules = append(initial.Rules, Rule{ID: "intrusion", Pattern: `(?i)intrusion`, Action: ActionRoute, Route: "security"})
+	if _, err := OpenStore(initial, taxonomy.Current, nil, "", func(*Compiled) {}); err == nil {
+		t.Fatal("expected the store to refuse a route that is not configured")
+	}
+}
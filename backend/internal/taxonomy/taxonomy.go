@@ -0,0 +1,196 @@
+package taxonomy
+
+import (
+	"errors"
+	"fmt"
+	"log-classifier/internal/config"
+	"strings"
+	"sync/atomic"
+)
+
+// Canonical label IDs used by the Go code itself.
+const (
+	Unclassified = "UNCLASSIFIED"
+	SecretLeak   = "SECRET_LEAK"
+)
+
+var ErrUnknownLabel = errors.New("unknown label")
+
+type Label struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Severity    string `json:"severity,omitempty"`
+	Parent      string `json:"parent,omitempty"`
+}
+
+// Registry is the canonical set of labels. Stage outputs are resolved
+// against it so that only known label IDs leave the pipeline.
+type Registry struct {
+	labels                map[string]Label
+	order                 []string
+	remap                 map[string]string
+	unknownToUnclassified bool
+}
+
+var defaultLabels = []Label{
+	{ID: "ERROR", Name: "Error", Description: "Any error condition", Severity: "error"},
+	{ID: "AUTH_ERROR", Name: "Authentication Error", Description: "Failed logins, expired or invalid credentials", Severity: "error", Parent: "ERROR"},
+	{ID: "DB_ERROR", Name: "Database Error", Description: "Database connectivity or query failures", Severity: "error", Parent: "ERROR"},
+	{ID: "WORKFLOW_ERROR", Name: "Workflow Error", Description: "Failures in business workflows and jobs", Severity: "error", Parent: "ERROR"},
+	{ID: "DEPRECATION_WARNING", Name: "Deprecation Warning", Description: "Use of deprecated features or APIs", Severity: "warning"},
+	{ID: "USER_ACTION", Name: "User Action", Description: "Logins, logouts and account changes", Severity: "info"},
+	{ID: "SYSTEM_NOTIFICATION", Name: "System Notification", Description: "Updates, reboots, uploads and cleanups", Severity: "info"},
+	{ID: "BACKUP", Name: "Backup Event", Description: "Backup start, completion and status", Severity: "info"},
+	{ID: "INFO", Name: "Informational Log", Description: "General informational messages", Severity: "info"},
+	{ID: SecretLeak, Name: "Secret Leak", Description: "Credentials or keys written to the log", Severity: "critical"},
+	unclassifiedLabel,
+}
+
+var unclassifiedLabel = Label{ID: Unclassified, Name: "Unclassified", Description: "No stage could classify the log", Severity: "unknown"}
+
+// New builds a registry and checks that parents exist and do not form
+// cycles. UNCLASSIFIED is always present.
+func New(labels []Label, remap map[string]string, unknownToUnclassified bool) (*Registry, error) {
+	r := &Registry{
+		labels:                make(map[string]Label, len(labels)+1),
+		remap:                 make(map[string]string, len(remap)),
+		unknownToUnclassified: unknownToUnclassified,
+	}
+
+	for _, l := range labels {
+		if l.ID == "" {
+			return nil, fmt.Errorf("taxonomy: label with empty id")
+		}
+		if _, dup := r.labels[l.ID]; dup {
+			return nil, fmt.Errorf("taxonomy: duplicate label %q", l.ID)
+		}
+		if l.Name == "" {
+			l.Name = l.ID
+		}
+		r.labels[l.ID] = l
+		r.order = append(r.order, l.ID)
+	}
+	if _, ok := r.labels[Unclassified]; !ok {
+		r.labels[Unclassified] = unclassifiedLabel
+		r.order = append(r.order, Unclassified)
+	}
+
+	for _, id := range r.order {
+		seen := map[string]bool{id: true}
+		for p := r.labels[id].Parent; p != ""; p = r.labels[p].Parent {
+			if _, ok := r.labels[p]; !ok {
+				return nil, fmt.Errorf("taxonomy: label %q has unknown parent %q", id, p)
+			}
+			if seen[p] {
+				return nil, fmt.Errorf("taxonomy: parent cycle through %q", p)
+			}
+			seen[p] = true
+		}
+	}
+
+	for from, to := range remap {
+		if _, ok := r.labels[to]; !ok {
+			return nil, fmt.Errorf("taxonomy: remap %q -> %q targets an unknown label", from, to)
+		}
+		r.remap[strings.ToUpper(from)] = to
+	}
+	return r, nil
+}
+
+// FromConfig builds a registry from cfg, falling back to the default
+// labels when none are configured.
+func FromConfig(cfg config.TaxonomyConfig) (*Registry, error) {
+	labels := defaultLabels
+	if len(cfg.Labels) > 0 {
+		labels = make([]Label, 0, len(cfg.Labels))
+		for _, l := range cfg.Labels {
+			labels = append(labels, Label{
+				ID:          l.ID,
+				Name:        l.Name,
+				Description: l.Description,
+				Severity:    l.Severity,
+				Parent:      l.Parent,
+			})
+		}
+	}
+	return New(labels, cfg.Remap, cfg.Unknown == "unclassified")
+}
+
+func (r *Registry) Lookup(id string) (Label, bool) {
+	l, ok := r.labels[id]
+	return l, ok
+}
+
+// Name returns the display name of id, or id itself if it is unknown.
+func (r *Registry) Name(id string) string {
+	if l, ok := r.labels[id]; ok {
+		return l.Name
+	}
+	return id
+}
+
+// Resolve maps a label ID reported by a stage onto the registry. IDs are
+// matched exactly, then case-insensitively, then through the remap table.
+// remapped reports whether the returned label differs from id.
+func (r *Registry) Resolve(id string) (label Label, remapped bool, err error) {
+	if l, ok := r.labels[id]; ok {
+		return l, false, nil
+	}
+
+	upper := strings.ToUpper(strings.TrimSpace(id))
+	if l, ok := r.labels[upper]; ok {
+		return l, true, nil
+	}
+	if to, ok := r.remap[upper]; ok {
+		return r.labels[to], true, nil
+	}
+	if r.unknownToUnclassified {
+		return r.labels[Unclassified], true, nil
+	}
+	return Label{}, false, fmt.Errorf("%w %q", ErrUnknownLabel, id)
+}
+
+// Path returns the ancestors of id followed by id itself, root first,
+// e.g. ["ERROR", "DB_ERROR"].
+func (r *Registry) Path(id string) []string {
+	l, ok := r.labels[id]
+	if !ok {
+		return nil
+	}
+	path := []string{id}
+	for l.Parent != "" {
+		path = append([]string{l.Parent}, path...)
+		l = r.labels[l.Parent]
+	}
+	return path
+}
+
+// Labels returns all labels in declaration order.
+func (r *Registry) Labels() []Label {
+	out := make([]Label, 0, len(r.order))
+	for _, id := range r.order {
+		out = append(out, r.labels[id])
+	}
+	return out
+}
+
+var current atomic.Pointer[Registry]
+
+func init() {
+	r, err := New(defaultLabels, nil, false)
+	if err != nil {
+		panic(err)
+	}
+	current.Store(r)
+}
+
+// Current returns the registry in use.
+func Current() *Registry {
+	return current.Load()
+}
+
+// Set replaces the registry in use.
+func Set(r *Registry) {
+	current.Store(r)
+}
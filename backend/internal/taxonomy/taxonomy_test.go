@@ -0,0 +1,71 @@
+package taxonomy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	r, err := New(defaultLabels, map[string]string{"db_failure": "DB_ERROR"}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		in       string
+		want     string
+		remapped bool
+	}{
+		{"DB_ERROR", "DB_ERROR", false},
+		{"db_error", "DB_ERROR", true},
+		{"DB_FAILURE", "DB_ERROR", true},
+	}
+	for _, c := range cases {
+		l, remapped, err := r.Resolve(c.in)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.in, err)
+		}
+		if l.ID != c.want || remapped != c.remapped {
+			t.Errorf("%s: expected %s (remapped=%v), got %s (remapped=%v)", c.in, c.want, c.remapped, l.ID, remapped)
+		}
+	}
+
+	if _, _, err := r.Resolve("MADE_UP"); !errors.Is(err, ErrUnknownLabel) {
+		t.Fatalf("expected ErrUnknownLabel, got %v", err)
+	}
+}
+
+func TestResolve_UnknownToUnclassified(t *testing.T) {
+	r, _ := New(defaultLabels, nil, true)
+
+	l, remapped, err := r.Resolve("MADE_UP")
+	if err != nil || l.ID != Unclassified || !remapped {
+		t.Fatalf("expected remap to UNCLASSIFIED, got %v %v %v", l.ID, remapped, err)
+	}
+}
+
+func TestNew_RejectsBadParents(t *testing.T) {
+	if _, err := New([]Label{{ID: "A", Parent: "MISSING"}}, nil, false); err == nil {
+		t.Fatalf("expected unknown parent error")
+	}
+	if _, err := New([]Label{{ID: "A", Parent: "B"}, {ID: "B", Parent: "A"}}, nil, false); err == nil {
+		t.Fatalf("expected cycle error")
+	}
+	if _, err := New(defaultLabels, map[string]string{"X": "MISSING"}, false); err == nil {
+		t.Fatalf("expected bad remap target error")
+	}
+}
+
+func TestPath(t *testing.T) {
+	r, _ := New(defaultLabels, nil, false)
+
+	if got := r.Path("DB_ERROR"); len(got) != 2 || got[0] != "ERROR" || got[1] != "DB_ERROR" {
+		t.Fatalf("expected [ERROR DB_ERROR], got %v", got)
+	}
+	if got := r.Path("INFO"); len(got) != 1 {
+		t.Fatalf("expected [INFO], got %v", got)
+	}
+	if got := r.Path("MADE_UP"); got != nil {
+		t.Fatalf("expected nil path, got %v", got)
+	}
+}
@@ -0,0 +1,285 @@
+// Package templates mines log templates online with Drain, a fixed-depth
+// parse tree (He et al., "Drain: An Online Log Parsing Approach with Fixed
+// Depth Tree", ICWS 2017).
+//
+// Messages are split on whitespace. The first tree level groups them by
+// token count and the next Depth-2 levels by their leading tokens; tokens
+// with digits go to a wildcard branch. Each leaf holds a list of templates,
+// and a message joins the most similar one if enough of their tokens agree,
+// turning the positions that differ into Wildcard.
+package templates
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Wildcard stands for a variable token in a template.
+const Wildcard = "<*>"
+
+// Config tunes the miner. Zero fields take the defaults.
+type Config struct {
+	Depth        int     // tree depth including root and length levels, default 4
+	Similarity   float64 // fraction of tokens that must agree, default 0.6
+	MaxChildren  int     // children per inner node before new tokens share Wildcard, default 100
+	MaxTemplates int     // templates kept, default 10000
+}
+
+func (c Config) withDefaults() Config {
+	if c.Depth < 3 {
+		c.Depth = 4
+	}
+	if c.Similarity <= 0 {
+		c.Similarity = 0.6
+	}
+	if c.MaxChildren <= 0 {
+		c.MaxChildren = 100
+	}
+	if c.MaxTemplates <= 0 {
+		c.MaxTemplates = 10000
+	}
+	return c
+}
+
+// Template is a learned message template and the label decided for it.
+type Template struct {
+	ID     int      `json:"id"`
+	Tokens []string `json:"-"`
+	Count  uint64   `json:"count"`
+
+	// LabelID is empty until a classification was learned for the
+	// template; Classifier is the stage that decided it.
+	LabelID    string  `json:"label_id,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Classifier string  `json:"classifier,omitempty"`
+
+	// Ambiguous is set once the template, after generalizing, was
+	// classified differently than before. It is never labeled again.
+	Ambiguous bool `json:"ambiguous,omitempty"`
+
+	// unverified is the label cleared when the template last generalized,
+	// until the next classification confirms or contradicts it.
+	unverified string
+}
+
+// String renders the template with Wildcard for variable tokens.
+func (t Template) String() string {
+	return strings.Join(t.Tokens, " ")
+}
+
+type node struct {
+	children  map[string]*node
+	templates []*Template
+}
+
+// Miner learns templates from a stream of messages. It is safe for
+// concurrent use.
+type Miner struct {
+	cfg Config
+
+	mu     sync.Mutex
+	root   *node
+	byID   map[int]*Template
+	nextID int
+}
+
+func NewMiner(cfg Config) *Miner {
+	return &Miner{
+		cfg:    cfg.withDefaults(),
+		root:   &node{children: make(map[string]*node)},
+		byID:   make(map[int]*Template),
+		nextID: 1,
+	}
+}
+
+// Add files msg under its template, creating or generalizing one as
+// needed, and returns a copy of it. ok is false when msg matches no
+// template and MaxTemplates has been reached.
+func (m *Miner) Add(msg string) (t Template, ok bool) {
+	tokens := strings.Fields(msg)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	leaf := m.leaf(tokens, true)
+	best := m.closest(leaf, tokens)
+	switch {
+	case best != nil:
+		generalized := false
+		for i, tok := range tokens {
+			if best.Tokens[i] != tok && best.Tokens[i] != Wildcard {
+				best.Tokens[i] = Wildcard
+				generalized = true
+			}
+		}
+		// the label was decided for the narrower template and may not
+		// hold for the messages it now covers
+		if generalized && best.LabelID != "" {
+			best.unverified = best.LabelID
+			best.LabelID, best.Confidence, best.Classifier = "", 0, ""
+		}
+	case len(m.byID) < m.cfg.MaxTemplates:
+		best = &Template{ID: m.nextID, Tokens: slices.Clone(tokens)}
+		m.nextID++
+		m.byID[best.ID] = best
+		leaf.templates = append(leaf.templates, best)
+	default:
+		return Template{}, false
+	}
+
+	best.Count++
+	return best.copy(), true
+}
+
+// Match returns the template msg would join, without learning from it.
+func (m *Miner) Match(msg string) (Template, bool) {
+	tokens := strings.Fields(msg)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	leaf := m.leaf(tokens, false)
+	if leaf == nil {
+		return Template{}, false
+	}
+	if t := m.closest(leaf, tokens); t != nil {
+		return t.copy(), true
+	}
+	return Template{}, false
+}
+
+// SetLabel records the classification decided for template id and
+// reports whether it did. A label already recorded is kept, so a
+// template is classified once per generalization. A label that
+// contradicts the one cleared when the template last generalized marks
+// the template Ambiguous instead.
+func (m *Miner) SetLabel(id int, labelID string, confidence float64, classifier string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.byID[id]
+	if !ok || t.LabelID != "" || t.Ambiguous {
+		return false
+	}
+	if t.unverified != "" && t.unverified != labelID {
+		t.Ambiguous = true
+		return false
+	}
+	t.unverified = ""
+	t.LabelID = labelID
+	t.Confidence = confidence
+	t.Classifier = classifier
+	return true
+}
+
+// Templates returns a copy of every template, most frequent first.
+func (m *Miner) Templates() []Template {
+	m.mu.Lock()
+	out := make([]Template, 0, len(m.byID))
+	for _, t := range m.byID {
+		out = append(out, t.copy())
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(out, func(a, b Template) int {
+		if a.Count != b.Count {
+			if a.Count > b.Count {
+				return -1
+			}
+			return 1
+		}
+		return a.ID - b.ID
+	})
+	return out
+}
+
+// Len returns the number of templates.
+func (m *Miner) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.byID)
+}
+
+// leaf walks the tree for tokens, creating the path when create is set.
+// It returns nil if the path does not exist and create is false.
+func (m *Miner) leaf(tokens []string, create bool) *node {
+	// the length level is never capped, so every leaf holds templates
+	// of a single length
+	key := lengthKey(len(tokens))
+	n, ok := m.root.children[key]
+	if !ok {
+		if !create {
+			return nil
+		}
+		n = &node{children: make(map[string]*node)}
+		m.root.children[key] = n
+	}
+	for i := 0; n != nil && i < m.cfg.Depth-2 && i < len(tokens); i++ {
+		n = m.child(n, tokens[i], create)
+	}
+	return n
+}
+
+func (m *Miner) child(n *node, tok string, create bool) *node {
+	if c, ok := n.children[tok]; ok {
+		return c
+	}
+	if hasDigit(tok) {
+		tok = Wildcard
+	} else if len(n.children) >= m.cfg.MaxChildren {
+		tok = Wildcard
+	}
+	if c, ok := n.children[tok]; ok || !create {
+		return c
+	}
+	c := &node{children: make(map[string]*node)}
+	n.children[tok] = c
+	return c
+}
+
+// closest returns the template in leaf most similar to tokens, or nil if
+// none reaches the similarity threshold. Ties go to the template with
+// more wildcards already, which is the more general one.
+func (m *Miner) closest(leaf *node, tokens []string) *Template {
+	var best *Template
+	bestSim, bestWild := -1.0, -1
+	for _, t := range leaf.templates {
+		same, wild := 0, 0
+		for i, tok := range t.Tokens {
+			switch {
+			case tok == Wildcard:
+				wild++
+			case tok == tokens[i]:
+				same++
+			}
+		}
+		sim := 1.0
+		if len(tokens) > 0 {
+			sim = float64(same) / float64(len(tokens))
+		}
+		if sim > bestSim || (sim == bestSim && wild > bestWild) {
+			best, bestSim, bestWild = t, sim, wild
+		}
+	}
+	if best == nil || bestSim < m.cfg.Similarity {
+		return nil
+	}
+	return best
+}
+
+func (t *Template) copy() Template {
+	c := *t
+	c.Tokens = slices.Clone(t.Tokens)
+	return c
+}
+
+func lengthKey(n int) string {
+	return strconv.Itoa(n)
+}
+
+func hasDigit(s string) bool {
+	return strings.IndexFunc(s, unicode.IsDigit) >= 0
+}
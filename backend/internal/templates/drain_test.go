@@ -0,0 +1,110 @@
+package templates
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMiner_LearnsTemplates(t *testing.T) {
+	m := NewMiner(Config{})
+	first, _ := m.Add("Connection to 10.0.0.1 failed after 3 retries")
+	m.Add("Connection to 10.0.0.7 failed after 5 retries")
+	m.Add("User alice logged in")
+	got, _ := m.Add("Connection to db-2 failed after 1 retries")
+
+	if got.ID != first.ID {
+		t.Fatalf("expected the messages to share template %d, got %d", first.ID, got.ID)
+	}
+	if want := "Connection to <*> failed after <*> retries"; got.String() != want {
+		t.Fatalf("expected template %q, got %q", want, got.String())
+	}
+	if got.Count != 3 || m.Len() != 2 {
+		t.Fatalf("expected count 3 and 2 templates, got count %d and %d templates", got.Count, m.Len())
+	}
+}
+
+func TestMiner_SeparatesDissimilarMessages(t *testing.T) {
+	m := NewMiner(Config{Similarity: 0.5})
+	a, _ := m.Add("disk full on node 7")
+	b, _ := m.Add("disk quota exceeded for alice") // same length and first token
+	c, _ := m.Add("disk full")
+
+	if a.ID == b.ID || a.ID == c.ID {
+		t.Fatalf("expected distinct templates, got %d %d %d", a.ID, b.ID, c.ID)
+	}
+}
+
+func TestMiner_MatchAndLabel(t *testing.T) {
+	m := NewMiner(Config{})
+	tmpl, _ := m.Add("Backup completed in 42s")
+
+	if _, ok := m.Match("Something else entirely"); ok {
+		t.Fatalf("unexpected match")
+	}
+	got, ok := m.Match("Backup completed in 7s")
+	if !ok || got.ID != tmpl.ID || got.Count != 1 {
+		t.Fatalf("expected match without learning, got %+v", got)
+	}
+
+	m.SetLabel(tmpl.ID, "BACKUP", 0.9, "bert")
+	m.SetLabel(tmpl.ID, "INFO", 0.99, "llm") // first label wins
+	got, _ = m.Add("Backup completed in 42s")
+	if got.LabelID != "BACKUP" || got.Classifier != "bert" {
+		t.Fatalf("expected BACKUP from bert, got %+v", got)
+	}
+}
+
+func TestMiner_GeneralizingReverifiesLabel(t *testing.T) {
+	m := NewMiner(Config{})
+	tmpl, _ := m.Add("User alice login succeeded")
+	m.SetLabel(tmpl.ID, "USER_ACTION", 0.9, "bert")
+
+	// opposite meaning, but three of four tokens agree
+	got, _ := m.Add("User alice login failed")
+	if got.ID != tmpl.ID || got.LabelID != "" {
+		t.Fatalf("expected the generalized template to lose its label, got %+v", got)
+	}
+	if m.SetLabel(tmpl.ID, "AUTH_ERROR", 0.9, "bert") {
+		t.Fatalf("a contradicting label must not be recorded")
+	}
+	got, _ = m.Add("User alice login succeeded")
+	if got.LabelID != "" || !got.Ambiguous {
+		t.Fatalf("expected an ambiguous template, got %+v", got)
+	}
+	if m.SetLabel(tmpl.ID, "USER_ACTION", 0.9, "bert") {
+		t.Fatalf("an ambiguous template must not be labeled again")
+	}
+
+	same, _ := m.Add("Backup completed in 42s")
+	m.SetLabel(same.ID, "BACKUP", 0.9, "bert")
+	m.Add("Backup completed in 7s")
+	if !m.SetLabel(same.ID, "BACKUP", 0.8, "bert") {
+		t.Fatalf("a confirmed label should be recorded again")
+	}
+	if got, _ := m.Add("Backup completed in 3s"); got.LabelID != "BACKUP" || got.Confidence != 0.8 {
+		t.Fatalf("expected BACKUP to be reused, got %+v", got)
+	}
+}
+
+func TestMiner_MaxTemplates(t *testing.T) {
+	m := NewMiner(Config{MaxTemplates: 2})
+	m.Add("alpha beta")
+	m.Add("gamma delta epsilon")
+	if _, ok := m.Add("one two three four"); ok {
+		t.Fatalf("expected the miner to be full")
+	}
+	if _, ok := m.Add("alpha beta"); !ok {
+		t.Fatalf("known templates should still match when full")
+	}
+}
+
+func TestMiner_CapsChildren(t *testing.T) {
+	m := NewMiner(Config{MaxChildren: 2, Similarity: 0.5})
+	for i := 0; i < 10; i++ {
+		m.Add(fmt.Sprintf("service%c started", 'a'+i))
+	}
+	// services beyond the cap share the wildcard branch and merge
+	if m.Len() > 3 {
+		t.Fatalf("expected at most 3 templates, got %d", m.Len())
+	}
+}
@@ -18,6 +18,10 @@ type result struct {
 }
 
 func ProcessLogs(logs []models.LogEntry, workers int) []*models.ClassificationResult {
+	return ProcessLogsWithOptions(logs, workers, classifier.Options{})
+}
+
+func ProcessLogsWithOptions(logs []models.LogEntry, workers int, opts classifier.Options) []*models.ClassificationResult {
 	jobs := make(chan job, len(logs))
 	results := make(chan result, len(logs))
 
@@ -31,7 +35,7 @@ func ProcessLogs(logs []models.LogEntry, workers int) []*models.ClassificationRe
 			defer metrics.ActiveWorkers.Dec()
 
 			for j := range jobs {
-				r := classifier.Classify(j.entry)
+				r := classifier.ClassifyWithOptions(j.entry, opts)
 				results <- result{index: j.index, value: r}
 			}
 		}(w)